@@ -0,0 +1,266 @@
+package pipeline
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"sync"
+	"time"
+)
+
+//Default number of simultaneous job submissions
+const DEFAULT_BATCH_CONCURRENCY = 4
+
+//Default time between two batch status queries
+const DEFAULT_BATCH_POLL_INTERVAL = 2 * time.Second
+
+//Default number of batch status queries a job may be missing from before
+//it's looked up on its own
+const DEFAULT_BATCH_MISSING_POLLS = 3
+
+//Set, wrapped, as the error of the jobs that disappeared from the server
+var ErrJobLost = errors.New("Job is no longer in the server")
+
+//Job to be submitted as part of a batch
+type BatchItem struct {
+	Request JobRequest //Job request, its BatchId is overwritten
+	Data    []byte     //Zipped data sent along with the request, may be empty
+}
+
+//Outcome of a single job of the batch
+type BatchJob struct {
+	Request JobRequest //Request as submitted
+	Job     Job        //Last known state of the job
+	Err     error      //Submission or result collection error
+}
+
+//Aggregated state of the batch
+type BatchProgress struct {
+	BatchId   string
+	Total     int            //Number of jobs in the batch
+	Submitted int            //Jobs accepted by the framework
+	Rejected  int            //Jobs whose submission failed
+	Lost      int            //Submitted jobs that disappeared from the server
+	Finished  int            //Jobs in a final status
+	Statuses  map[string]int //Number of submitted jobs per status
+}
+
+//Tells whether nothing else is going to change in the batch
+func (b BatchProgress) Done() bool {
+	return b.Submitted+b.Rejected == b.Total && b.Finished+b.Lost == b.Submitted
+}
+
+//Submits a set of job requests under the same batch id and tracks them
+//until all of them are finished
+type BatchRunner struct {
+	BatchId      string                           //Batch id, generated if empty
+	Concurrency  int                              //Maximum number of simultaneous submissions
+	PollInterval time.Duration                    //Time between batch status queries
+	MissingPolls int                              //Queries a job may be missing from the batch before it's looked up on its own
+	Cleanup      bool                             //Delete the batch from the server once finished, kept if any result couldn't be collected
+	Progress     func(BatchProgress)              //Called every time the batch status is queried
+	Results      func(job Job) (io.Writer, error) //Where to store the results of each job, results are not collected if nil
+	pipeline     Pipeline
+}
+
+//Creates a new batch runner with the default settings
+func NewBatchRunner(p Pipeline) *BatchRunner {
+	return &BatchRunner{
+		Concurrency:  DEFAULT_BATCH_CONCURRENCY,
+		PollInterval: DEFAULT_BATCH_POLL_INTERVAL,
+		MissingPolls: DEFAULT_BATCH_MISSING_POLLS,
+		pipeline:     p,
+	}
+}
+
+//Generates a random batch id
+func NewBatchId() string {
+	buf := make([]byte, 8)
+	if _, err := rand.Read(buf); err != nil {
+		return fmt.Sprintf("batch-%x", time.Now().UnixNano())
+	}
+	return "batch-" + hex.EncodeToString(buf)
+}
+
+//Submits the items and waits until every job is finished or the context
+//is done. Errors of single jobs are reported in the returned BatchJobs,
+//err is only set when the batch couldn't be tracked.
+func (b *BatchRunner) Run(ctx context.Context, items []BatchItem) (batchId string, jobs []BatchJob, err error) {
+	batchId = b.BatchId
+	if batchId == "" {
+		batchId = NewBatchId()
+	}
+	jobs = b.submit(ctx, batchId, items)
+	if err = ctx.Err(); err != nil {
+		return
+	}
+	if err = b.track(ctx, batchId, jobs); err != nil {
+		return
+	}
+	failed := 0
+	if b.Results != nil {
+		failed = b.collect(jobs)
+	}
+	//the server copy is the only one left of the results not saved
+	if b.Cleanup && failed > 0 {
+		return batchId, jobs, fmt.Errorf("Results of %v jobs couldn't be collected, batch %v not deleted", failed, batchId)
+	}
+	if b.Cleanup {
+		_, err = b.pipeline.DeleteBatch(batchId)
+	}
+	return
+}
+
+//Sends the job requests with bounded concurrency
+func (b *BatchRunner) submit(ctx context.Context, batchId string, items []BatchItem) []BatchJob {
+	concurrency := b.Concurrency
+	if concurrency < 1 {
+		concurrency = 1
+	}
+	jobs := make([]BatchJob, len(items))
+	sem := make(chan struct{}, concurrency)
+	var wg sync.WaitGroup
+	for i, item := range items {
+		req := item.Request
+		req.BatchId = batchId
+		jobs[i].Request = req
+		select {
+		case <-ctx.Done():
+			jobs[i].Err = ctx.Err()
+			continue
+		case sem <- struct{}{}:
+		}
+		wg.Add(1)
+		go func(i int, data []byte) {
+			defer wg.Done()
+			defer func() { <-sem }()
+			jobs[i].Job, jobs[i].Err = b.pipeline.JobRequest(jobs[i].Request, data)
+		}(i, item.Data)
+	}
+	wg.Wait()
+	return jobs
+}
+
+//Queries the batch until all the submitted jobs are finished. Jobs missing
+//from the batch for MissingPolls queries are looked up on their own, and
+//marked as lost if the server answers that they don't exist
+func (b *BatchRunner) track(ctx context.Context, batchId string, jobs []BatchJob) error {
+	index := make(map[string]int)
+	for i, job := range jobs {
+		if job.Err == nil {
+			index[job.Job.Id] = i
+		}
+	}
+	maxMissing := b.MissingPolls
+	if maxMissing < 1 {
+		maxMissing = DEFAULT_BATCH_MISSING_POLLS
+	}
+	missing := make(map[int]int)
+	pipeline := b.pipeline.WithContext(ctx)
+	start := time.Now()
+	update := func(i int, job Job) {
+		pipeline.observeJob(job)
+		if Finished(job.Status) && !Finished(jobs[i].Job.Status) {
+			pipeline.recordJob(job, time.Since(start))
+		}
+		jobs[i].Job = job
+	}
+	for {
+		batch, err := pipeline.Batch(batchId)
+		if err != nil {
+			return err
+		}
+		listed := make(map[int]bool)
+		for _, job := range batch.Jobs {
+			if i, ok := index[job.Id]; ok {
+				listed[i] = true
+				update(i, job)
+			}
+		}
+		for id, i := range index {
+			if listed[i] || jobs[i].Err != nil || Finished(jobs[i].Job.Status) {
+				delete(missing, i)
+				continue
+			}
+			if missing[i]++; missing[i] < maxMissing {
+				continue
+			}
+			job, status, err := pipeline.jobWithStatus(id, 0)
+			if err != nil {
+				if ctx.Err() != nil {
+					return ctx.Err()
+				}
+				//other errors may be transient, try again on the next poll
+				if status == 404 {
+					jobs[i].Err = fmt.Errorf("%w: %v is not in batch %v (%v)", ErrJobLost, id, batchId, err)
+				}
+				continue
+			}
+			update(i, job)
+		}
+		progress := batchProgress(batchId, jobs)
+		if b.Progress != nil {
+			b.Progress(progress)
+		}
+		if progress.Done() {
+			return nil
+		}
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-time.After(b.PollInterval):
+		}
+	}
+}
+
+//Fetches the results of the finished jobs, returns the number of failures
+func (b *BatchRunner) collect(jobs []BatchJob) (failed int) {
+	for i, job := range jobs {
+		if job.Err != nil || job.Job.Status == JOB_FAIL {
+			continue
+		}
+		w, err := b.Results(job.Job)
+		if err != nil {
+			jobs[i].Err = err
+			failed++
+			continue
+		}
+		if w == nil {
+			continue
+		}
+		if _, err := b.pipeline.Results(job.Job.Id, w); err != nil {
+			jobs[i].Err = err
+			failed++
+		}
+	}
+	return
+}
+
+//Computes the aggregated state of the jobs
+func batchProgress(batchId string, jobs []BatchJob) BatchProgress {
+	progress := BatchProgress{
+		BatchId:  batchId,
+		Total:    len(jobs),
+		Statuses: make(map[string]int),
+	}
+	for _, job := range jobs {
+		if errors.Is(job.Err, ErrJobLost) {
+			progress.Submitted++
+			progress.Lost++
+			continue
+		}
+		if job.Err != nil {
+			progress.Rejected++
+			continue
+		}
+		progress.Submitted++
+		progress.Statuses[job.Job.Status]++
+		if Finished(job.Job.Status) {
+			progress.Finished++
+		}
+	}
+	return progress
+}
@@ -0,0 +1,198 @@
+package pipeline
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/capitancambio/restclient"
+)
+
+func batchXml(status string, ids ...string) string {
+	jobs := ""
+	for _, id := range ids {
+		jobs += fmt.Sprintf(`<job id="%v" href="http://example.org/ws/jobs/%v" status="%v"/>`, id, id, status)
+	}
+	return `<jobs xmlns="http://www.daisy.org/ns/pipeline/data">` + jobs + `</jobs>`
+}
+
+func TestBatchRunner(t *testing.T) {
+	var submitted, queried int32
+	recorder := &mockRecorder{}
+	pipeline := createPipeline(routedClientMock(map[string]mockHandler{
+		"POST jobs": func(*restclient.RequestResponse) (string, int) {
+			n := atomic.AddInt32(&submitted, 1)
+			return fmt.Sprintf(`<job xmlns="http://www.daisy.org/ns/pipeline/data" id="job-%v" status="IDLE"/>`, n), 201
+		},
+		"GET batch/": func(*restclient.RequestResponse) (string, int) {
+			if atomic.AddInt32(&queried, 1) == 1 {
+				return batchXml(JOB_RUNNING, "job-1", "job-2", "job-3"), 200
+			}
+			return batchXml(JOB_DONE, "job-1", "job-2", "job-3"), 200
+		},
+		"GET jobs/": func(rr *restclient.RequestResponse) (string, int) {
+			if strings.Contains(rr.Url, "/result") {
+				return "zip", 200
+			}
+			return `<job xmlns="http://www.daisy.org/ns/pipeline/data" status="DONE"><results href="r"/></job>`, 200
+		},
+		"DELETE batch/": xmlRoute("", 204),
+	}, recorder))
+	history := NewDurationHistory(10)
+	pipeline.SetDurationHistory(history)
+
+	runner := NewBatchRunner(pipeline)
+	runner.PollInterval = time.Millisecond
+	runner.Cleanup = true
+	var progress []BatchProgress
+	runner.Progress = func(p BatchProgress) { progress = append(progress, p) }
+	results := make(map[string]*bytes.Buffer)
+	runner.Results = func(job Job) (io.Writer, error) {
+		results[job.Id] = &bytes.Buffer{}
+		return results[job.Id], nil
+	}
+	items := []BatchItem{{Request: JobRequest{}}, {Request: JobRequest{}}, {Request: JobRequest{}, Data: []byte("data")}}
+	batchId, jobs, err := runner.Run(context.Background(), items)
+	if err != nil {
+		t.Fatalf("Unexpected error %v", err)
+	}
+	if !strings.HasPrefix(batchId, "batch-") {
+		t.Errorf("Wrong batch id %v", batchId)
+	}
+	for _, job := range jobs {
+		if job.Err != nil || job.Job.Status != JOB_DONE || job.Request.BatchId != batchId {
+			t.Errorf("Wrong job %+v", job)
+		}
+		if results[job.Job.Id].String() != "zip" {
+			t.Errorf(T_STRING, "results", "zip", results[job.Job.Id].String())
+		}
+	}
+	if len(progress) != 2 || progress[0].Statuses[JOB_RUNNING] != 3 || !progress[1].Done() {
+		t.Errorf("Wrong progress %+v", progress)
+	}
+	if recorder.count("DELETE batch/"+batchId) != 1 {
+		t.Errorf("Batch not deleted")
+	}
+	if _, ok := history.Mean(); !ok {
+		t.Errorf("Durations of the jobs not recorded")
+	}
+}
+
+func TestBatchRunnerRejected(t *testing.T) {
+	pipeline := createPipeline(routedClientMock(map[string]mockHandler{
+		"POST jobs":  xmlRoute("", 400),
+		"GET batch/": xmlRoute(batchXml(JOB_DONE), 200),
+	}, &mockRecorder{}))
+	runner := NewBatchRunner(pipeline)
+	runner.BatchId = "mine"
+	batchId, jobs, err := runner.Run(context.Background(), []BatchItem{{}, {}})
+	if err != nil {
+		t.Fatalf("Unexpected error %v", err)
+	}
+	if batchId != "mine" {
+		t.Errorf(T_STRING, "batch id", "mine", batchId)
+	}
+	for _, job := range jobs {
+		if job.Err == nil {
+			t.Errorf("Expected error not set")
+		}
+	}
+}
+
+func TestBatchRunnerMissingJobs(t *testing.T) {
+	var submitted int32
+	recorder := &mockRecorder{}
+	pipeline := createPipeline(routedClientMock(map[string]mockHandler{
+		"POST jobs": func(*restclient.RequestResponse) (string, int) {
+			n := atomic.AddInt32(&submitted, 1)
+			return fmt.Sprintf(`<job xmlns="http://www.daisy.org/ns/pipeline/data" id="job-%v" status="IDLE"/>`, n), 201
+		},
+		//job 2 was deleted and job 3 isn't listed by the server
+		"GET batch/":     xmlRoute(batchXml(JOB_DONE, "job-1"), 200),
+		"GET jobs/job-2": xmlRoute("", 404),
+		"GET jobs/job-3": xmlRoute(`<job xmlns="http://www.daisy.org/ns/pipeline/data" id="job-3" status="DONE"/>`, 200),
+	}, recorder))
+	runner := NewBatchRunner(pipeline)
+	runner.PollInterval = time.Millisecond
+	runner.MissingPolls = 2
+	var last BatchProgress
+	runner.Progress = func(p BatchProgress) { last = p }
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	_, jobs, err := runner.Run(ctx, []BatchItem{{}, {}, {}})
+	if err != nil {
+		t.Fatalf("Unexpected error %v", err)
+	}
+	//submissions are concurrent, the ids don't follow the items
+	for _, job := range jobs {
+		if lost := job.Job.Id == "job-2"; lost != errors.Is(job.Err, ErrJobLost) {
+			t.Errorf("Wrong error for %v: %v", job.Job.Id, job.Err)
+		}
+		if job.Job.Id == "job-3" && job.Job.Status != JOB_DONE {
+			t.Errorf("Missing job not looked up %+v", job.Job)
+		}
+	}
+	if last.Lost != 1 || last.Finished != 2 || !last.Done() {
+		t.Errorf("Wrong progress %+v", last)
+	}
+	if n := recorder.count("GET batch/"); n != 2 {
+		t.Errorf(T_STRING, "batch queries", 2, n)
+	}
+}
+
+func TestBatchRunnerTransientLookupError(t *testing.T) {
+	var lookups int32
+	pipeline := createPipeline(routedClientMock(map[string]mockHandler{
+		"POST jobs":  xmlRoute(`<job xmlns="http://www.daisy.org/ns/pipeline/data" id="job-1" status="IDLE"/>`, 201),
+		"GET batch/": xmlRoute(batchXml(JOB_DONE), 200),
+		"GET jobs/job-1": func(*restclient.RequestResponse) (string, int) {
+			if atomic.AddInt32(&lookups, 1) == 1 {
+				return "", 503
+			}
+			return `<job xmlns="http://www.daisy.org/ns/pipeline/data" id="job-1" status="DONE"/>`, 200
+		},
+	}, &mockRecorder{}))
+	runner := NewBatchRunner(pipeline)
+	runner.PollInterval = time.Millisecond
+	runner.MissingPolls = 1
+	_, jobs, err := runner.Run(context.Background(), []BatchItem{{}})
+	if err != nil {
+		t.Fatalf("Unexpected error %v", err)
+	}
+	if jobs[0].Err != nil || jobs[0].Job.Status != JOB_DONE || lookups != 2 {
+		t.Errorf("Job dropped after a transient error %+v %v", jobs[0], lookups)
+	}
+}
+
+func TestBatchRunnerKeepsBatchWithoutResults(t *testing.T) {
+	recorder := &mockRecorder{}
+	pipeline := createPipeline(routedClientMock(map[string]mockHandler{
+		"POST jobs":         xmlRoute(`<job xmlns="http://www.daisy.org/ns/pipeline/data" id="job-1" status="IDLE"/>`, 201),
+		"GET batch/":        xmlRoute(batchXml(JOB_DONE, "job-1"), 200),
+		"GET jobs/job-1":    xmlRoute(`<job xmlns="http://www.daisy.org/ns/pipeline/data" id="job-1" status="DONE"><results href="r"/></job>`, 200),
+		"GET jobs/job-1/re": xmlRoute("", 500),
+		"DELETE batch/":     xmlRoute("", 204),
+	}, recorder))
+	runner := NewBatchRunner(pipeline)
+	runner.Cleanup = true
+	runner.Results = func(Job) (io.Writer, error) { return &bytes.Buffer{}, nil }
+	_, jobs, err := runner.Run(context.Background(), []BatchItem{{}})
+	if err == nil || jobs[0].Err == nil {
+		t.Errorf("Collection failure not reported %v %+v", err, jobs[0])
+	}
+	if n := recorder.count("DELETE batch/"); n != 0 {
+		t.Errorf("Batch deleted with uncollected results")
+	}
+}
+
+func TestNewBatchId(t *testing.T) {
+	if NewBatchId() == NewBatchId() {
+		t.Errorf("Batch ids are not unique")
+	}
+}
@@ -0,0 +1,274 @@
+package pipeline
+
+import (
+	"crypto/hmac"
+	"encoding/xml"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+//Kinds of callbacks the framework is able to post
+type CallbackType string
+
+const (
+	CALLBACK_STATUS   CallbackType = "status"
+	CALLBACK_MESSAGES CallbackType = "messages"
+)
+
+//Maximum size of a callback body
+const MAX_CALLBACK_SIZE = 10 * 1024 * 1024
+
+//Default time window around the signing time in which callbacks are accepted
+const DEFAULT_CALLBACK_MAX_AGE = 5 * time.Minute
+
+//Error messages
+var (
+	ErrCallbackType      = errors.New("Unknown callback type")
+	ErrCallbackSignature = errors.New("Callback signature is not valid")
+	ErrCallbackUnsigned  = errors.New("Callback is not signed")
+	ErrCallbackExpired   = errors.New("Callback signature has expired")
+	ErrCallbackReplayed  = errors.New("Callback has already been received")
+)
+
+//Function called with every callback received for a job. For status callbacks
+//only the job status is meaningful, for messages callbacks job.Messages holds
+//the new messages
+type CallbackHandler func(kind CallbackType, job Job)
+
+//http.Handler receiving the job callbacks posted by the framework.
+//Signatures are checked against the urls handed out by Callback, so the
+//path the receiver is mounted under may differ, e.g. behind a proxy:
+//
+//  receiver := NewCallbackReceiver("http://myhost:8080/pipeline/callbacks/")
+//  http.Handle("/callbacks/", http.StripPrefix("/callbacks", receiver))
+//  req.Callback = receiver.Callbacks(10)
+type CallbackReceiver struct {
+	BaseUrl     string           //public url of the receiver as seen from the framework
+	RequireAuth bool             //reject unsigned callbacks, only meaningful if credentials are set
+	MaxAge      time.Duration    //accepted distance between the signing time and the local clock, DEFAULT_CALLBACK_MAX_AGE if zero
+	Now         func() time.Time //clock, time.Now if nil
+
+	mutex     sync.RWMutex
+	handlers  map[string][]*jobHandler
+	fallbacks []CallbackHandler
+	secrets   map[string]string
+	nonces    map[string]time.Time //nonces seen within the window by signing time
+}
+
+//Creates a new receiver which will be reachable by the framework at baseUrl
+func NewCallbackReceiver(baseUrl string) *CallbackReceiver {
+	if !strings.HasSuffix(baseUrl, "/") {
+		baseUrl = baseUrl + "/"
+	}
+	return &CallbackReceiver{
+		BaseUrl:  baseUrl,
+		handlers: make(map[string][]*jobHandler),
+		secrets:  make(map[string]string),
+		nonces:   make(map[string]time.Time),
+	}
+}
+
+//Registers the credentials of a client so the callbacks signed by the
+//framework on behalf of it are verified
+func (c *CallbackReceiver) SetCredentials(clientKey, clientSecret string) {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+	c.secrets[clientKey] = clientSecret
+}
+
+//Handler registered for a job, a pointer so it can be told from the rest
+type jobHandler struct {
+	fn CallbackHandler
+}
+
+//Registers a handler for the callbacks of the given job, the returned
+//function removes this handler only
+func (c *CallbackReceiver) Handle(jobId string, handler CallbackHandler) (remove func()) {
+	registered := &jobHandler{handler}
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+	c.handlers[jobId] = append(c.handlers[jobId], registered)
+	return func() {
+		c.mutex.Lock()
+		defer c.mutex.Unlock()
+		handlers := c.handlers[jobId]
+		for i, h := range handlers {
+			if h == registered {
+				handlers = append(handlers[:i:i], handlers[i+1:]...)
+				break
+			}
+		}
+		if len(handlers) == 0 {
+			delete(c.handlers, jobId)
+		} else {
+			c.handlers[jobId] = handlers
+		}
+	}
+}
+
+//Registers a handler for the callbacks of jobs without a specific handler
+func (c *CallbackReceiver) HandleAll(handler CallbackHandler) {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+	c.fallbacks = append(c.fallbacks, handler)
+}
+
+//Removes all the handlers of the given job
+func (c *CallbackReceiver) Remove(jobId string) {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+	delete(c.handlers, jobId)
+}
+
+//Returns the callback entry of the given type pointing to this receiver.
+//frequency is the number of messages between calls, ignored for status callbacks
+func (c *CallbackReceiver) Callback(kind CallbackType, frequency int) Callback {
+	cb := Callback{
+		Href: c.BaseUrl + string(kind),
+		Type: string(kind),
+	}
+	if kind == CALLBACK_MESSAGES {
+		cb.Frequency = strconv.Itoa(frequency)
+	}
+	return cb
+}
+
+//Returns the status and messages callback entries to be set in a JobRequest
+func (c *CallbackReceiver) Callbacks(frequency int) []Callback {
+	return []Callback{
+		c.Callback(CALLBACK_STATUS, 0),
+		c.Callback(CALLBACK_MESSAGES, frequency),
+	}
+}
+
+//Decodes the posted job and dispatches it to the registered handlers
+func (c *CallbackReceiver) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if r.Method != "POST" {
+		w.Header().Set("Allow", "POST")
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	kind, err := callbackType(r.URL.Path)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusNotFound)
+		return
+	}
+	if err := c.verify(r, kind); err != nil {
+		http.Error(w, err.Error(), http.StatusUnauthorized)
+		return
+	}
+	job := Job{}
+	err = xml.NewDecoder(io.LimitReader(r.Body, MAX_CALLBACK_SIZE)).Decode(&job)
+	if err != nil {
+		http.Error(w, fmt.Sprintf("Error parsing callback XML: %v", err), http.StatusBadRequest)
+		return
+	}
+	c.dispatch(kind, job)
+	w.WriteHeader(http.StatusOK)
+}
+
+func (c *CallbackReceiver) dispatch(kind CallbackType, job Job) {
+	c.mutex.RLock()
+	var handlers []CallbackHandler
+	if registered, ok := c.handlers[job.Id]; ok {
+		for _, h := range registered {
+			handlers = append(handlers, h.fn)
+		}
+	} else {
+		handlers = append(handlers, c.fallbacks...)
+	}
+	c.mutex.RUnlock()
+	for _, handler := range handlers {
+		handler(kind, job)
+	}
+}
+
+//Gets the callback type from the last segment of the path
+func callbackType(path string) (CallbackType, error) {
+	kind := CallbackType(path[strings.LastIndex(path, "/")+1:])
+	switch kind {
+	case CALLBACK_STATUS, CALLBACK_MESSAGES:
+		return kind, nil
+	}
+	return "", ErrCallbackType
+}
+
+//Checks the signature added by the framework to the callback url, and that
+//the callback is recent and not replayed. Callbacks are accepted as they
+//come when no credentials are registered
+func (c *CallbackReceiver) verify(r *http.Request, kind CallbackType) error {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+	if len(c.secrets) == 0 {
+		return nil
+	}
+	query := r.URL.RawQuery
+	idx := strings.LastIndex(query, "sign=")
+	if idx < 0 {
+		if c.RequireAuth {
+			return ErrCallbackUnsigned
+		}
+		return nil
+	}
+	values, err := url.ParseQuery(query)
+	if err != nil {
+		return ErrCallbackSignature
+	}
+	secret, ok := c.secrets[values.Get("authid")]
+	if !ok {
+		return ErrCallbackSignature
+	}
+	//the framework signs the href handed out by Callback up to the sign
+	//parameter, the path of the request may have been rewritten
+	signed := c.BaseUrl + string(kind) + "?" + strings.TrimSuffix(query[:idx], "&")
+	expected := signature(signed, secret)
+	if !hmac.Equal([]byte(expected), []byte(values.Get("sign"))) {
+		return ErrCallbackSignature
+	}
+	return c.fresh(values.Get("time"), values.Get("nonce"))
+}
+
+//Checks that the signing time is within MaxAge of the local clock and that
+//the nonce hasn't been seen in that window, the lock must be held
+func (c *CallbackReceiver) fresh(timestamp, nonce string) error {
+	signedAt, err := time.Parse(SIGN_TIME_FORMAT, timestamp)
+	if err != nil {
+		return ErrCallbackExpired
+	}
+	maxAge := c.MaxAge
+	if maxAge <= 0 {
+		maxAge = DEFAULT_CALLBACK_MAX_AGE
+	}
+	now := time.Now
+	if c.Now != nil {
+		now = c.Now
+	}
+	current := now()
+	if age := current.Sub(signedAt); age > maxAge || age < -maxAge {
+		return ErrCallbackExpired
+	}
+	if nonce == "" {
+		return ErrCallbackSignature
+	}
+	if c.nonces == nil {
+		c.nonces = make(map[string]time.Time)
+	}
+	//older nonces are rejected by the time check anyway
+	for seen, at := range c.nonces {
+		if current.Sub(at) > maxAge {
+			delete(c.nonces, seen)
+		}
+	}
+	if _, ok := c.nonces[nonce]; ok {
+		return ErrCallbackReplayed
+	}
+	c.nonces[nonce] = signedAt
+	return nil
+}
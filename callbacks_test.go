@@ -0,0 +1,176 @@
+package pipeline
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+)
+
+func postCallback(receiver *CallbackReceiver, url, body string) *httptest.ResponseRecorder {
+	rec := httptest.NewRecorder()
+	req := httptest.NewRequest("POST", url, strings.NewReader(body))
+	receiver.ServeHTTP(rec, req)
+	return rec
+}
+
+func TestCallbackReceiverDispatch(t *testing.T) {
+	receiver := NewCallbackReceiver("http://localhost:9999/callbacks")
+	var kinds []CallbackType
+	var jobs []Job
+	receiver.Handle("job-id-01", func(kind CallbackType, job Job) {
+		kinds = append(kinds, kind)
+		jobs = append(jobs, job)
+	})
+	fallback := 0
+	receiver.HandleAll(func(CallbackType, Job) { fallback++ })
+
+	rec := postCallback(receiver, "http://localhost:9999/callbacks/messages", jobStatus)
+	if rec.Code != http.StatusOK {
+		t.Fatalf(T_STRING, "status", http.StatusOK, rec.Code)
+	}
+	if len(jobs) != 1 || kinds[0] != CALLBACK_MESSAGES {
+		t.Fatalf("Handler not called %v", kinds)
+	}
+	if len(jobs[0].Messages.Message) != 1 || jobs[0].Messages.Message[0].Sequence != 22 {
+		t.Errorf("Wrong messages %+v", jobs[0].Messages)
+	}
+	postCallback(receiver, "http://localhost:9999/callbacks/status", jobsXml)
+	if fallback != 0 {
+		t.Errorf("Fallback called with a non job body")
+	}
+	postCallback(receiver, "http://localhost:9999/callbacks/status",
+		strings.Replace(jobCreationOk, "job-id-01", "other", 1))
+	if fallback != 1 {
+		t.Errorf(T_STRING, "fallback calls", 1, fallback)
+	}
+}
+
+func TestCallbackReceiverRemoveHandler(t *testing.T) {
+	receiver := NewCallbackReceiver("http://localhost:9999/callbacks")
+	first, second, fallback := 0, 0, 0
+	remove := receiver.Handle("job-id-01", func(CallbackType, Job) { first++ })
+	removeSecond := receiver.Handle("job-id-01", func(CallbackType, Job) { second++ })
+	receiver.HandleAll(func(CallbackType, Job) { fallback++ })
+	remove()
+	remove()
+	postCallback(receiver, "http://localhost:9999/callbacks/status", jobStatus)
+	if first != 0 || second != 1 {
+		t.Errorf("Wrong handlers called %v %v", first, second)
+	}
+	removeSecond()
+	postCallback(receiver, "http://localhost:9999/callbacks/status", jobStatus)
+	if second != 1 || fallback != 1 {
+		t.Errorf("Fallback not used once the handlers are removed %v %v", second, fallback)
+	}
+}
+
+func TestCallbackReceiverErrors(t *testing.T) {
+	receiver := NewCallbackReceiver("http://localhost:9999/callbacks/")
+	if rec := postCallback(receiver, "http://localhost:9999/callbacks/other", jobStatus); rec.Code != http.StatusNotFound {
+		t.Errorf(T_STRING, "status", http.StatusNotFound, rec.Code)
+	}
+	if rec := postCallback(receiver, "http://localhost:9999/callbacks/status", "not xml"); rec.Code != http.StatusBadRequest {
+		t.Errorf(T_STRING, "status", http.StatusBadRequest, rec.Code)
+	}
+	rec := httptest.NewRecorder()
+	receiver.ServeHTTP(rec, httptest.NewRequest("GET", "http://localhost:9999/callbacks/status", nil))
+	if rec.Code != http.StatusMethodNotAllowed {
+		t.Errorf(T_STRING, "status", http.StatusMethodNotAllowed, rec.Code)
+	}
+}
+
+func TestCallbackReceiverSignature(t *testing.T) {
+	receiver := NewCallbackReceiver("http://localhost:9999/callbacks/")
+	receiver.SetCredentials("cli", "shhh")
+	receiver.RequireAuth = true
+	called := 0
+	receiver.HandleAll(func(CallbackType, Job) { called++ })
+
+	url, _ := Signer{ClientKey: "cli", ClientSecret: "shhh"}.Sign(receiver.Callback(CALLBACK_STATUS, 0).Href)
+	if rec := postCallback(receiver, url, jobCreationOk); rec.Code != http.StatusOK {
+		t.Errorf(T_STRING, "status", http.StatusOK, rec.Code)
+	}
+	url, _ = Signer{ClientKey: "cli", ClientSecret: "wrong"}.Sign(receiver.Callback(CALLBACK_STATUS, 0).Href)
+	if rec := postCallback(receiver, url, jobCreationOk); rec.Code != http.StatusUnauthorized {
+		t.Errorf(T_STRING, "status", http.StatusUnauthorized, rec.Code)
+	}
+	if rec := postCallback(receiver, receiver.Callback(CALLBACK_STATUS, 0).Href, jobCreationOk); rec.Code != http.StatusUnauthorized {
+		t.Errorf(T_STRING, "status", http.StatusUnauthorized, rec.Code)
+	}
+	if called != 1 {
+		t.Errorf(T_STRING, "calls", 1, called)
+	}
+}
+
+func TestCallbackReceiverRewrittenPath(t *testing.T) {
+	receiver := NewCallbackReceiver("https://public.example.org/pipeline/callbacks/")
+	receiver.SetCredentials("cli", "shhh")
+	called := 0
+	receiver.HandleAll(func(CallbackType, Job) { called++ })
+	handler := http.StripPrefix("/internal", receiver)
+
+	signed, _ := Signer{ClientKey: "cli", ClientSecret: "shhh"}.Sign(receiver.Callback(CALLBACK_STATUS, 0).Href)
+	query := signed[strings.Index(signed, "?"):]
+	rec := httptest.NewRecorder()
+	handler.ServeHTTP(rec, httptest.NewRequest("POST", "http://10.0.0.1:8080/internal/status"+query, strings.NewReader(jobCreationOk)))
+	if rec.Code != http.StatusOK || called != 1 {
+		t.Errorf(T_STRING, "status", http.StatusOK, rec.Code)
+	}
+	//signed for another kind
+	rec = httptest.NewRecorder()
+	handler.ServeHTTP(rec, httptest.NewRequest("POST", "http://10.0.0.1:8080/internal/messages"+query, strings.NewReader(jobCreationOk)))
+	if rec.Code != http.StatusUnauthorized {
+		t.Errorf(T_STRING, "status", http.StatusUnauthorized, rec.Code)
+	}
+}
+
+func TestCallbackReceiverReplay(t *testing.T) {
+	now := time.Date(2014, 5, 7, 16, 0, 0, 0, time.UTC)
+	receiver := NewCallbackReceiver("http://localhost:9999/callbacks/")
+	receiver.SetCredentials("cli", "shhh")
+	receiver.Now = func() time.Time { return now }
+	receiver.HandleAll(func(CallbackType, Job) {})
+	sign := func(at time.Time, nonce string) string {
+		signer := Signer{ClientKey: "cli", ClientSecret: "shhh",
+			Now:   func() time.Time { return at },
+			Nonce: func() (string, error) { return nonce, nil },
+		}
+		url, _ := signer.Sign(receiver.Callback(CALLBACK_STATUS, 0).Href)
+		return url
+	}
+	url := sign(now.Add(-time.Minute), "1")
+	if rec := postCallback(receiver, url, jobCreationOk); rec.Code != http.StatusOK {
+		t.Errorf(T_STRING, "status", http.StatusOK, rec.Code)
+	}
+	for name, url := range map[string]string{
+		"replayed": url,
+		"old":      sign(now.Add(-time.Hour), "2"),
+		"future":   sign(now.Add(time.Hour), "3"),
+	} {
+		if rec := postCallback(receiver, url, jobCreationOk); rec.Code != http.StatusUnauthorized {
+			t.Errorf("%v callback accepted", name)
+		}
+	}
+	//the nonce is forgotten once the time alone rejects it
+	now = now.Add(DEFAULT_CALLBACK_MAX_AGE)
+	postCallback(receiver, sign(now, "4"), jobCreationOk)
+	if len(receiver.nonces) != 1 {
+		t.Errorf(T_STRING, "nonces", 1, len(receiver.nonces))
+	}
+}
+
+func TestCallbacks(t *testing.T) {
+	receiver := NewCallbackReceiver("http://localhost:9999/callbacks")
+	cbs := receiver.Callbacks(10)
+	if len(cbs) != 2 {
+		t.Fatalf(T_STRING, "callbacks", 2, len(cbs))
+	}
+	if cbs[0].Href != "http://localhost:9999/callbacks/status" || cbs[0].Type != "status" || cbs[0].Frequency != "" {
+		t.Errorf("Wrong status callback %+v", cbs[0])
+	}
+	if cbs[1].Href != "http://localhost:9999/callbacks/messages" || cbs[1].Frequency != "10" {
+		t.Errorf("Wrong messages callback %+v", cbs[1])
+	}
+}
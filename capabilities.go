@@ -0,0 +1,118 @@
+package pipeline
+
+import (
+	"errors"
+	"fmt"
+	"sync"
+
+	"github.com/capitancambio/restclient"
+)
+
+//Returned, wrapped with the entry name, when the server doesn't provide the
+//api entry
+var ErrUnsupported = errors.New("Not supported by the server")
+
+//Support of the api entries learnt by probing, shared by the copies of the
+//pipeline
+type capabilities struct {
+	mutex     sync.RWMutex
+	supported map[string]bool
+}
+
+func newCapabilities() *capabilities {
+	return &capabilities{supported: make(map[string]bool)}
+}
+
+func (c *capabilities) get(entry string) (supported, known bool) {
+	if c == nil {
+		return
+	}
+	c.mutex.RLock()
+	defer c.mutex.RUnlock()
+	supported, known = c.supported[entry]
+	return
+}
+
+func (c *capabilities) set(entry string, supported bool) {
+	if c == nil {
+		return
+	}
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+	c.supported[entry] = supported
+}
+
+//Tells whether the server provides the api entry, as far as probing, the
+//responses of the server and SetSupported tell. Entries are taken as
+//supported when nothing is known
+func (p Pipeline) Supports(entry string) bool {
+	if _, ok := lookupEntry(entry); !ok {
+		return false
+	}
+	supported, known := p.caps.get(entry)
+	return supported || !known
+}
+
+//Overrides the support of an entry, e.g. to refuse the entries known to
+//be missing without probing them
+func (p *Pipeline) SetSupported(entry string, supported bool) {
+	if p.caps == nil {
+		p.caps = newCapabilities()
+	}
+	p.caps.set(entry, supported)
+}
+
+//Checks the entries by calling them, only the entries using GET and
+//without arguments can be probed, e.g. API_QUEUE
+func (p *Pipeline) Probe(entries ...string) error {
+	if p.caps == nil {
+		p.caps = newCapabilities()
+	}
+	for _, entry := range entries {
+		api, ok := lookupEntry(entry)
+		if !ok || api.method != "GET" || hasArgs(entry) {
+			return fmt.Errorf("Api entry %v can't be probed", entry)
+		}
+		//the response is discarded
+		req, err := p.newResquest(entry, &struct{}{}, nil)
+		if err != nil {
+			return err
+		}
+		status, err := p.chain()(req)
+		if err != nil && err != restclient.UnexpectedStatus {
+			return err
+		}
+		p.caps.set(entry, !missingEntry(status))
+	}
+	return nil
+}
+
+//Tells whether the url of the entry has placeholders
+func hasArgs(entry string) bool {
+	api, _ := lookupEntry(entry)
+	return len(templateArgs(api.urlPath)) > 0
+}
+
+//Statuses meaning that the server doesn't know the entry
+func missingEntry(status int) bool {
+	return status == 404 || status == 405 || status == 501
+}
+
+//Error for an unsupported entry
+func unsupported(entry string) error {
+	return fmt.Errorf("%w: %v", ErrUnsupported, entry)
+}
+
+//Learns from the response, entries without arguments answering 405 or 501
+//aren't provided by the server. A 404 may come from a proxy while the
+//server restarts, so it isn't taken as a missing entry
+func (p Pipeline) learn(req *Request, status int) error {
+	if hasArgs(req.Entry) {
+		return nil
+	}
+	if status == 405 || status == 501 {
+		p.caps.set(req.Entry, false)
+		return unsupported(req.Entry)
+	}
+	return nil
+}
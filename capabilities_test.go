@@ -0,0 +1,100 @@
+package pipeline
+
+import (
+	"errors"
+	"strings"
+	"testing"
+)
+
+func aliveVersion(version string) string {
+	return strings.Replace(aliveXml, "'1.6'", "'"+version+"'", 1)
+}
+
+func TestSupportsOverrides(t *testing.T) {
+	recorder := &mockRecorder{}
+	pipeline := createPipeline(routedClientMock(map[string]mockHandler{
+		"GET alive": xmlRoute(aliveVersion("1.6"), 200),
+	}, recorder))
+	if pipeline.Supports("unknown") {
+		t.Errorf("Unknown entry supported")
+	}
+	//the version of the server says nothing about the entries
+	if _, err := pipeline.Connect(); err != nil {
+		t.Fatalf("Unexpected error %v", err)
+	}
+	for _, entry := range []string{API_JOBS, API_QUEUE, API_BATCH, API_STYLESHEET_PARAMETERS} {
+		if !pipeline.Supports(entry) {
+			t.Errorf("%v not supported", entry)
+		}
+	}
+	pipeline.SetSupported(API_BATCH, false)
+	if _, err := pipeline.Batch("batch"); !errors.Is(err, ErrUnsupported) {
+		t.Errorf(T_STRING, "error", ErrUnsupported, err)
+	}
+	if recorder.count("GET batch") != 0 {
+		t.Errorf("Unsupported entry requested %v", recorder.calls)
+	}
+	pipeline.SetSupported(API_BATCH, true)
+	if !pipeline.Supports(API_BATCH) {
+		t.Errorf("Override not applied")
+	}
+}
+
+func TestConnectKeepsOverrides(t *testing.T) {
+	pipeline := createPipeline(routedClientMock(map[string]mockHandler{
+		"GET alive": xmlRoute(aliveVersion("1.6"), 200),
+	}, &mockRecorder{}))
+	pipeline.SetSupported(API_STYLESHEET_PARAMETERS, true)
+	pipeline.SetSupported(API_SIZE, false)
+	if _, err := pipeline.Connect(); err != nil {
+		t.Fatalf("Unexpected error %v", err)
+	}
+	if !pipeline.Supports(API_STYLESHEET_PARAMETERS) || pipeline.Supports(API_SIZE) {
+		t.Errorf("Overrides dropped by Connect")
+	}
+}
+
+func TestProbe(t *testing.T) {
+	recorder := &mockRecorder{}
+	pipeline := createPipeline(routedClientMock(map[string]mockHandler{
+		"GET admin/sizes": xmlRoute(sizesXml, 200),
+	}, recorder))
+	if err := pipeline.Probe(API_QUEUE, API_SIZE); err != nil {
+		t.Fatalf("Unexpected error %v", err)
+	}
+	if pipeline.Supports(API_QUEUE) || !pipeline.Supports(API_SIZE) {
+		t.Errorf("Wrong probe results queue:%v size:%v", pipeline.Supports(API_QUEUE), pipeline.Supports(API_SIZE))
+	}
+	if _, err := pipeline.Queue(); !errors.Is(err, ErrUnsupported) {
+		t.Errorf(T_STRING, "error", ErrUnsupported, err)
+	}
+	if err := pipeline.Probe(API_JOB); err == nil {
+		t.Errorf("Entry with arguments probed")
+	}
+}
+
+func TestLearnUnsupported(t *testing.T) {
+	recorder := &mockRecorder{}
+	pipeline, _ := NewPipeline("http://localhost:8181/ws/")
+	pipeline.BaseUrl = "base/"
+	pipeline.clientMaker = routedClientMock(map[string]mockHandler{
+		"GET queue": xmlRoute("", 501),
+	}, recorder)
+	if _, err := pipeline.Queue(); !errors.Is(err, ErrUnsupported) {
+		t.Errorf(T_STRING, "error", ErrUnsupported, err)
+	}
+	pipeline.Queue()
+	if recorder.count("GET queue") != 1 {
+		t.Errorf("Missing entry requested again %v", recorder.calls)
+	}
+	//a 404 may come from a proxy
+	pipeline.Sizes()
+	pipeline.Sizes()
+	if recorder.count("GET admin/sizes") != 2 || !pipeline.Supports(API_SIZE) {
+		t.Errorf("404 taken as unsupported entry %v", recorder.calls)
+	}
+	//entries with arguments may be missing because of the argument
+	if _, err := pipeline.Job("job", 0); errors.Is(err, ErrUnsupported) {
+		t.Errorf("Missing job taken as unsupported entry")
+	}
+}
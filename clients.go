@@ -0,0 +1,87 @@
+package pipeline
+
+import (
+	"crypto/rand"
+	"errors"
+	"fmt"
+	"math/big"
+	"regexp"
+)
+
+//Roles of the clients of the web service
+type Role string
+
+const (
+	ROLE_ADMIN     Role = "ADMIN"
+	ROLE_CLIENTAPP Role = "CLIENTAPP"
+)
+
+//Tells whether the role is one of the known ones
+func (r Role) Valid() bool {
+	return r == ROLE_ADMIN || r == ROLE_CLIENTAPP
+}
+
+//Error messages
+var ErrSecretUnknown = errors.New("The server didn't return the client secret, set it to modify the client")
+
+//Length of the secrets generated for new clients
+const DEFAULT_SECRET_LENGTH = 32
+
+//Shortest secret accepted by GenerateSecret
+const MIN_SECRET_LENGTH = 16
+
+const secretAlphabet = "abcdefghijklmnopqrstuvwxyzABCDEFGHIJKLMNOPQRSTUVWXYZ0123456789"
+
+var clientIdRegexp = regexp.MustCompile(`^[A-Za-z0-9._@-]+$`)
+
+//Generates a random secret of the given length using crypto/rand
+func GenerateSecret(length int) (string, error) {
+	if length < MIN_SECRET_LENGTH {
+		return "", fmt.Errorf("Secrets must be at least %v characters long", MIN_SECRET_LENGTH)
+	}
+	max := big.NewInt(int64(len(secretAlphabet)))
+	secret := make([]byte, length)
+	for i := range secret {
+		n, err := rand.Int(rand.Reader, max)
+		if err != nil {
+			return "", err
+		}
+		secret[i] = secretAlphabet[n.Int64()]
+	}
+	return string(secret), nil
+}
+
+//Checks the client before sending it to the server, the secret isn't
+//checked as the server may not return it
+func (c Client) Validate() error {
+	if c.Id == "" {
+		return errors.New("Client id is required")
+	}
+	if !clientIdRegexp.MatchString(c.Id) {
+		return fmt.Errorf("Invalid client id %q, only letters, digits and ._@- are allowed", c.Id)
+	}
+	if !c.Role.Valid() {
+		return fmt.Errorf("Invalid role %q, expected %v or %v", c.Role, ROLE_ADMIN, ROLE_CLIENTAPP)
+	}
+	if c.Priority != "" && !c.Priority.Valid() {
+		return fmt.Errorf("Invalid priority %q, expected low, medium or high", c.Priority)
+	}
+	return nil
+}
+
+//Returns current with the non empty fields of update
+func mergeClient(current, update Client) Client {
+	if update.Secret != "" {
+		current.Secret = update.Secret
+	}
+	if update.Role != "" {
+		current.Role = update.Role
+	}
+	if update.Contact != "" {
+		current.Contact = update.Contact
+	}
+	if update.Priority != "" {
+		current.Priority = update.Priority
+	}
+	return current
+}
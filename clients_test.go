@@ -0,0 +1,92 @@
+package pipeline
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/capitancambio/restclient"
+)
+
+func TestGenerateSecret(t *testing.T) {
+	s1, err := GenerateSecret(DEFAULT_SECRET_LENGTH)
+	if err != nil {
+		t.Fatalf("Unexpected error %v", err)
+	}
+	s2, _ := GenerateSecret(DEFAULT_SECRET_LENGTH)
+	if len(s1) != DEFAULT_SECRET_LENGTH || s1 == s2 {
+		t.Errorf("Wrong secrets %v %v", s1, s2)
+	}
+	if strings.Trim(s1, secretAlphabet) != "" {
+		t.Errorf("Secret out of the alphabet %v", s1)
+	}
+	if _, err := GenerateSecret(8); err == nil {
+		t.Errorf("Expected error not thrown")
+	}
+}
+
+func TestClientValidate(t *testing.T) {
+	valid := Client{Id: "my-app_1", Role: ROLE_CLIENTAPP, Priority: PRIORITY_HIGH}
+	if err := valid.Validate(); err != nil {
+		t.Errorf("Unexpected error %v", err)
+	}
+	invalid := []Client{
+		{Role: ROLE_ADMIN},
+		{Id: "with space", Role: ROLE_ADMIN},
+		{Id: "app/1", Role: ROLE_ADMIN},
+		{Id: "app", Role: "ROOT"},
+		{Id: "app", Role: ROLE_ADMIN, Priority: "urgent"},
+	}
+	for _, c := range invalid {
+		if err := c.Validate(); err == nil {
+			t.Errorf("Expected error for %+v", c)
+		}
+	}
+}
+
+func TestNewClientGeneratesSecret(t *testing.T) {
+	pipeline := createPipeline(xmlClientMock(`<client xmlns="http://www.daisy.org/ns/pipeline/data" id="app" role="CLIENTAPP"/>`, 201))
+	out, err := pipeline.NewClient(Client{Id: "app", Role: ROLE_CLIENTAPP})
+	if err != nil {
+		t.Fatalf("Unexpected error %v", err)
+	}
+	if len(out.Secret) != DEFAULT_SECRET_LENGTH {
+		t.Errorf("Generated secret not returned %+v", out)
+	}
+	if _, err := pipeline.NewClient(Client{Id: "app"}); err == nil {
+		t.Errorf("Expected validation error not thrown")
+	}
+}
+
+func TestMergeClient(t *testing.T) {
+	current := Client{Id: "app", Secret: "s", Role: ROLE_CLIENTAPP, Contact: "a@b.c", Priority: PRIORITY_LOW}
+	merged := mergeClient(current, Client{Id: "app", Priority: PRIORITY_HIGH})
+	exp := current
+	exp.Priority = PRIORITY_HIGH
+	if merged != exp {
+		t.Errorf(T_STRING, "client", exp, merged)
+	}
+}
+
+func TestModifyClientWithoutSecret(t *testing.T) {
+	recorder := &mockRecorder{}
+	var sent *Client
+	pipeline := createPipeline(routedClientMock(map[string]mockHandler{
+		"GET admin/clients/id": xmlRoute(`<client xmlns="http://www.daisy.org/ns/pipeline/data" id="id" role="ADMIN"/>`, 200),
+		"PUT admin/clients/id": func(rr *restclient.RequestResponse) (string, int) {
+			sent = rr.Data.(*Client)
+			return `<client xmlns="http://www.daisy.org/ns/pipeline/data" id="id" role="ADMIN"/>`, 200
+		},
+	}, recorder))
+	if _, err := pipeline.ModifyClient(Client{Id: "id", Contact: "me@example.org"}); err != ErrSecretUnknown {
+		t.Errorf(T_STRING, "error", ErrSecretUnknown, err)
+	}
+	if recorder.count("PUT") != 0 {
+		t.Errorf("Client modified without secret")
+	}
+	if _, err := pipeline.ModifyClient(Client{Id: "id", Secret: "new secret"}); err != nil {
+		t.Errorf("Unexpected error %v", err)
+	}
+	if sent == nil || sent.Secret != "new secret" {
+		t.Errorf("Wrong client sent %+v", sent)
+	}
+}
@@ -0,0 +1,70 @@
+package pipeline
+
+import (
+	"errors"
+)
+
+//Error messages
+var ErrNoCredentials = errors.New("The server requires authentication but no credentials are set")
+
+//Capabilities of the server, learnt when connecting
+type ServerInfo struct {
+	Version        Version //Zero if the version couldn't be parsed
+	RawVersion     string  //Version as reported by the server
+	Authentication bool    //Requests must be signed
+	LocalFs        bool    //Jobs may refer to files in the server file system
+}
+
+//Calls alive and records the capabilities of the server, copies of the
+//pipeline made afterwards share them. Once connected requests are only
+//signed if the server requires authentication. ErrNoCredentials is returned
+//if it does and there are no credentials
+func (p *Pipeline) Connect() (info ServerInfo, err error) {
+	alive, err := p.Alive()
+	if err != nil {
+		return
+	}
+	info = ServerInfo{
+		RawVersion:     alive.Version,
+		Authentication: alive.Authentication,
+		LocalFs:        alive.FsAllow,
+	}
+	//unknown formats disable the version dependent features
+	info.Version, _ = ParseVersion(alive.Version)
+	if info.Authentication && p.authenticator == nil {
+		return info, ErrNoCredentials
+	}
+	p.server = &info
+	//probes and overrides made before connecting are kept
+	if p.caps == nil {
+		p.caps = newCapabilities()
+	}
+	return
+}
+
+//Returns the capabilities recorded by Connect, ok is false if not connected
+func (p Pipeline) Server() (info ServerInfo, ok bool) {
+	if p.server == nil {
+		return ServerInfo{}, false
+	}
+	return *p.server, true
+}
+
+//Returns the version of the server, asking for it if not connected. The
+//zero version is returned if it can't be parsed
+func (p Pipeline) serverVersion() (Version, error) {
+	if p.server != nil {
+		return p.server.Version, nil
+	}
+	alive, err := p.Alive()
+	if err != nil {
+		return Version{}, err
+	}
+	version, _ := ParseVersion(alive.Version)
+	return version, nil
+}
+
+//Tells whether the requests have to be signed
+func (p Pipeline) signing() bool {
+	return p.authenticator != nil && (p.server == nil || p.server.Authentication)
+}
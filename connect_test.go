@@ -0,0 +1,80 @@
+package pipeline
+
+import (
+	"strings"
+	"testing"
+)
+
+var aliveAuthXml = strings.Replace(aliveXml, "authentication='false'", "authentication='true'", 1)
+
+func TestConnect(t *testing.T) {
+	pipeline := createPipeline(xmlClientMock(aliveXml, 200))
+	if _, ok := pipeline.Server(); ok {
+		t.Errorf("Connected before calling Connect")
+	}
+	info, err := pipeline.Connect()
+	if err != nil {
+		t.Fatalf("Unexpected error %v", err)
+	}
+	exp := ServerInfo{Version: Version{Major: 1, Minor: 6}, RawVersion: "1.6", LocalFs: true}
+	if info != exp {
+		t.Errorf(T_STRING, "info", exp, info)
+	}
+	copied := pipeline
+	if res, ok := copied.Server(); !ok || res != exp {
+		t.Errorf(T_STRING, "server", exp, res)
+	}
+}
+
+func TestConnectRequiresCredentials(t *testing.T) {
+	pipeline := createPipeline(xmlClientMock(aliveAuthXml, 200))
+	if _, err := pipeline.Connect(); err != ErrNoCredentials {
+		t.Errorf(T_STRING, "error", ErrNoCredentials, err)
+	}
+	if _, ok := pipeline.Server(); ok {
+		t.Errorf("Failed connection recorded")
+	}
+	pipeline.SetCredentials("cli", "shhh")
+	if info, err := pipeline.Connect(); err != nil || !info.Authentication {
+		t.Errorf("Wrong connection %v %v", info, err)
+	}
+}
+
+func TestConnectSkipsSigning(t *testing.T) {
+	for _, test := range []struct {
+		alive  string
+		signed bool
+	}{
+		{aliveXml, false},
+		{aliveAuthXml, true},
+	} {
+		cli := xmlClientMock(test.alive, 200)().(*MockClient)
+		pipeline := createPipeline(func() doer { return cli })
+		pipeline.SetCredentials("cli", "shhh")
+		if _, err := pipeline.Connect(); err != nil {
+			t.Fatalf("Unexpected error %v", err)
+		}
+		pipeline.Alive()
+		if res := strings.Contains(cli.request.Url, "sign="); res != test.signed {
+			t.Errorf("Signed %v, expected %v: %v", res, test.signed, cli.request.Url)
+		}
+	}
+}
+
+func TestConnectedVersion(t *testing.T) {
+	queue := `<queue xmlns="http://www.daisy.org/ns/pipeline/data"/>`
+	recorder := &mockRecorder{}
+	pipeline := createPipeline(routedClientMock(map[string]mockHandler{
+		"GET alive":      xmlRoute(strings.Replace(aliveXml, "'1.6'", "'1.12'", 1), 200),
+		"POST queue/up/": xmlRoute(queue, 200),
+	}, recorder))
+	pipeline.SetMutatingMethodVersion(Version{Major: 1, Minor: 6})
+	if _, err := pipeline.Connect(); err != nil {
+		t.Fatalf("Unexpected error %v", err)
+	}
+	pipeline.MoveUp("job")
+	pipeline.MoveUp("job")
+	if recorder.count("GET alive") != 1 || recorder.count("POST queue/up/job") != 2 {
+		t.Errorf("Recorded version not used %v", recorder.calls)
+	}
+}
@@ -0,0 +1,158 @@
+package pipeline
+
+import (
+	"bufio"
+	"bytes"
+	"errors"
+	"fmt"
+	"os"
+	"strings"
+	"sync"
+	"time"
+)
+
+//Default environment variables holding the credentials
+const (
+	ENV_CLIENT_KEY    = "PIPELINE_CLIENT_KEY"
+	ENV_CLIENT_SECRET = "PIPELINE_CLIENT_SECRET"
+)
+
+//Key and secret of a web service client
+type Credentials struct {
+	ClientKey    string
+	ClientSecret string
+}
+
+//Supplies the credentials, it's consulted on every request so the
+//credentials may change over time
+type CredentialProvider interface {
+	Credentials() (Credentials, error)
+}
+
+//Adapts a function to a CredentialProvider
+type CredentialFunc func() (Credentials, error)
+
+func (f CredentialFunc) Credentials() (Credentials, error) {
+	return f()
+}
+
+//Always returns the same credentials
+func StaticCredentials(clientKey, clientSecret string) CredentialProvider {
+	return CredentialFunc(func() (Credentials, error) {
+		return Credentials{clientKey, clientSecret}, nil
+	})
+}
+
+//Reads the credentials from the environment variables on every request,
+//ENV_CLIENT_KEY and ENV_CLIENT_SECRET are used when the names are empty
+func EnvCredentials(keyVar, secretVar string) CredentialProvider {
+	if keyVar == "" {
+		keyVar = ENV_CLIENT_KEY
+	}
+	if secretVar == "" {
+		secretVar = ENV_CLIENT_SECRET
+	}
+	return CredentialFunc(func() (creds Credentials, err error) {
+		creds = Credentials{os.Getenv(keyVar), os.Getenv(secretVar)}
+		if creds.ClientKey == "" {
+			err = fmt.Errorf("Environment variable %v is not set", keyVar)
+		} else if creds.ClientSecret == "" {
+			err = fmt.Errorf("Environment variable %v is not set", secretVar)
+		}
+		return
+	})
+}
+
+//Parses a credentials file. It holds client_key and client_secret entries
+//as "name: value" or "name=value" lines, as in the command line tool
+//configuration; other entries and # comments are ignored
+func ParseCredentials(data []byte) (creds Credentials, err error) {
+	scanner := bufio.NewScanner(bytes.NewReader(data))
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		sep := strings.IndexAny(line, ":=")
+		if sep < 0 {
+			continue
+		}
+		value := strings.Trim(strings.TrimSpace(line[sep+1:]), `"'`)
+		switch strings.TrimSpace(line[:sep]) {
+		case "client_key":
+			creds.ClientKey = value
+		case "client_secret":
+			creds.ClientSecret = value
+		}
+	}
+	if err = scanner.Err(); err != nil {
+		return
+	}
+	if creds.ClientKey == "" || creds.ClientSecret == "" {
+		err = errors.New("Both client_key and client_secret are required")
+	}
+	return
+}
+
+//Reads the credentials file once
+func FileCredentials(path string) (CredentialProvider, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	creds, err := ParseCredentials(data)
+	if err != nil {
+		return nil, fmt.Errorf("Error reading credentials from %v: %v", path, err)
+	}
+	return StaticCredentials(creds.ClientKey, creds.ClientSecret), nil
+}
+
+//Credentials file reloaded when it's modified, so rotated secrets are picked
+//up without restarting. If the file can't be read the last good
+//credentials are kept
+type WatchedFileCredentials struct {
+	Path    string
+	mutex   sync.Mutex
+	modTime time.Time
+	size    int64
+	creds   Credentials
+	loaded  bool
+}
+
+//Creates the provider, the file is read on the first request
+func NewWatchedFileCredentials(path string) *WatchedFileCredentials {
+	return &WatchedFileCredentials{Path: path}
+}
+
+func (w *WatchedFileCredentials) Credentials() (Credentials, error) {
+	w.mutex.Lock()
+	defer w.mutex.Unlock()
+	info, err := os.Stat(w.Path)
+	if err != nil {
+		if w.loaded {
+			return w.creds, nil
+		}
+		return Credentials{}, err
+	}
+	if w.loaded && info.ModTime().Equal(w.modTime) && info.Size() == w.size {
+		return w.creds, nil
+	}
+	data, err := os.ReadFile(w.Path)
+	if err == nil {
+		var creds Credentials
+		if creds, err = ParseCredentials(data); err == nil {
+			w.creds, w.modTime, w.size, w.loaded = creds, info.ModTime(), info.Size(), true
+			return creds, nil
+		}
+	}
+	if w.loaded {
+		//probably caught in the middle of a write
+		return w.creds, nil
+	}
+	return Credentials{}, fmt.Errorf("Error reading credentials from %v: %v", w.Path, err)
+}
+
+//Signs the requests with the credentials supplied by the provider
+func (p *Pipeline) SetCredentialProvider(provider CredentialProvider) {
+	p.authenticator = Signer{Provider: provider}.Middleware()
+}
@@ -0,0 +1,124 @@
+package pipeline
+
+import (
+	"errors"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestParseCredentials(t *testing.T) {
+	data := `
+# dp2 configuration
+host: http://localhost
+client_key: cli
+client_secret: "shhh"
+`
+	creds, err := ParseCredentials([]byte(data))
+	if err != nil {
+		t.Fatalf("Unexpected error %v", err)
+	}
+	if creds != (Credentials{"cli", "shhh"}) {
+		t.Errorf(T_STRING, "credentials", Credentials{"cli", "shhh"}, creds)
+	}
+	creds, err = ParseCredentials([]byte("client_key=cli\nclient_secret = shhh\n"))
+	if err != nil || creds != (Credentials{"cli", "shhh"}) {
+		t.Errorf("Wrong properties parsing %v %v", creds, err)
+	}
+	if _, err = ParseCredentials([]byte("client_key: cli")); err == nil {
+		t.Errorf("Missing secret accepted")
+	}
+}
+
+func TestEnvCredentials(t *testing.T) {
+	t.Setenv(ENV_CLIENT_KEY, "cli")
+	t.Setenv(ENV_CLIENT_SECRET, "")
+	provider := EnvCredentials("", "")
+	if _, err := provider.Credentials(); err == nil || !strings.Contains(err.Error(), ENV_CLIENT_SECRET) {
+		t.Errorf("Missing secret not reported %v", err)
+	}
+	//read on every call
+	t.Setenv(ENV_CLIENT_SECRET, "shhh")
+	creds, err := provider.Credentials()
+	if err != nil || creds != (Credentials{"cli", "shhh"}) {
+		t.Errorf("Wrong credentials %v %v", creds, err)
+	}
+}
+
+func TestFileCredentials(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "config.yml")
+	if _, err := FileCredentials(path); err == nil {
+		t.Errorf("Missing file accepted")
+	}
+	os.WriteFile(path, []byte("client_key: cli\nclient_secret: shhh\n"), 0600)
+	provider, err := FileCredentials(path)
+	if err != nil {
+		t.Fatalf("Unexpected error %v", err)
+	}
+	os.WriteFile(path, []byte("client_key: cli\nclient_secret: other\n"), 0600)
+	if creds, _ := provider.Credentials(); creds.ClientSecret != "shhh" {
+		t.Errorf("File read again %v", creds)
+	}
+}
+
+func TestWatchedFileCredentials(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "config.yml")
+	provider := NewWatchedFileCredentials(path)
+	if _, err := provider.Credentials(); err == nil {
+		t.Errorf("Missing file accepted")
+	}
+	os.WriteFile(path, []byte("client_key: cli\nclient_secret: shhh\n"), 0600)
+	if creds, err := provider.Credentials(); err != nil || creds.ClientSecret != "shhh" {
+		t.Errorf("Wrong credentials %v %v", creds, err)
+	}
+	//rotated
+	os.WriteFile(path, []byte("client_key: cli\nclient_secret: rotated\n"), 0600)
+	os.Chtimes(path, time.Now(), time.Now().Add(time.Minute))
+	if creds, _ := provider.Credentials(); creds.ClientSecret != "rotated" {
+		t.Errorf(T_STRING, "secret", "rotated", creds.ClientSecret)
+	}
+	//half written files keep the last good credentials
+	os.WriteFile(path, []byte("client_key: cli\n"), 0600)
+	os.Chtimes(path, time.Now(), time.Now().Add(2*time.Minute))
+	if creds, err := provider.Credentials(); err != nil || creds.ClientSecret != "rotated" {
+		t.Errorf("Last credentials not kept %v %v", creds, err)
+	}
+}
+
+func TestCredentialProviderPerRequest(t *testing.T) {
+	cli := &MockClient{status: 204}
+	pipeline := createPipeline(func() doer { return cli })
+	secret := "first"
+	pipeline.SetCredentialProvider(CredentialFunc(func() (Credentials, error) {
+		return Credentials{"cli", secret}, nil
+	}))
+	signedWith := func() string {
+		pipeline.Halt("key")
+		idx := strings.Index(cli.request.Url, "&sign=")
+		sign, _ := url.QueryUnescape(cli.request.Url[idx+len("&sign="):])
+		for _, s := range []string{"first", "second"} {
+			if signature(cli.request.Url[:idx], s) == sign {
+				return s
+			}
+		}
+		return ""
+	}
+	if res := signedWith(); res != "first" {
+		t.Errorf(T_STRING, "secret", "first", res)
+	}
+	secret = "second"
+	if res := signedWith(); res != "second" {
+		t.Errorf(T_STRING, "secret", "second", res)
+	}
+
+	fail := errors.New("vault down")
+	pipeline.SetCredentialProvider(CredentialFunc(func() (Credentials, error) {
+		return Credentials{}, fail
+	}))
+	if err := pipeline.Halt("key"); err != fail {
+		t.Errorf(T_STRING, "error", fail, err)
+	}
+}
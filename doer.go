@@ -1,17 +1,10 @@
 package pipeline
 
 import (
-	"crypto/hmac"
-	"crypto/sha1"
-	"encoding/base64"
-	"encoding/xml"
+	"context"
 	"errors"
 	"fmt"
 	"io"
-	"log"
-	"math/rand"
-	"net/url"
-	"strings"
 	"time"
 
 	"github.com/capitancambio/restclient"
@@ -52,31 +45,6 @@ func errorHandler(handlers map[int]string) func(status int, respose restclient.R
 	}
 }
 
-//Adds the extra info + hash needed by the server
-func authenticator(cKey, cSecret string) func(*restclient.RequestResponse) {
-	return func(r *restclient.RequestResponse) {
-		uri := r.Url
-		//timestamp = Time.now.utc.strftime('%Y-%m-%dT%H:%M:%SZ')
-		timestamp := time.Now().Format("2006-01-02T15:04:05Z")
-		//nonce = generate_nonce
-		nonce := fmt.Sprintf("%v", rand.Int63())
-		//rpadding with zeros
-		nonce = strings.Repeat("0", 30-len(nonce)) + nonce
-
-		authPart := fmt.Sprintf("authid=%v&time=%v&nonce=%v", cKey, timestamp, nonce)
-		charater := "?"
-		if strings.Contains(uri, "?") {
-			charater = "&"
-		}
-		uri = uri + charater + authPart
-		hasher := hmac.New(sha1.New, []byte(cSecret))
-		hasher.Write([]byte(uri))
-		hash := base64.StdEncoding.EncodeToString(hasher.Sum(nil))
-		hashSt := url.QueryEscape(hash)
-		r.Url = uri + "&sign=" + hashSt
-	}
-}
-
 //Convinience interface for testing
 type doer interface {
 	Do(*restclient.RequestResponse) (status int, err error)
@@ -85,49 +53,61 @@ type doer interface {
 	SetContentType(string)
 }
 
-//Creates a new client setting the correct encoders
-func newClient() doer {
-	client := restclient.New()
-	client.EncoderSupplier = func(w io.Writer) restclient.Encoder {
-		return xml.NewEncoder(w)
-	}
-	client.DecoderSupplier = func(r io.Reader) restclient.Decoder {
-		return xml.NewDecoder(r)
-	}
-	return client
+//Request to an api entry, the response is stored in the embedded
+//RequestResponse once done
+type Request struct {
+	*restclient.RequestResponse
+	Entry      string            //Name of the api entry, one of the API_* constants
+	PathParams map[string]string //Named arguments of the entry, e.g. "job" for API_JOB
+	Context    context.Context   //Context of the call, never nil
+	redacted   string            //Url with the secret arguments redacted, for the logs
 }
 
-//Creates a new request object for the api entry and the target struct where the response for the sever will be decoded
-func (p Pipeline) newResquest(apiEntry string, targetPtr interface{}, postData interface{}, args ...interface{}) *restclient.RequestResponse {
-
-	if entry, ok := apiEntries[apiEntry]; ok {
-		url := p.BaseUrl + entry.urlPath
-		if len(args) > 0 {
-			url = fmt.Sprintf(url, args...)
-		}
-		r := &restclient.RequestResponse{
-			Url:            url,
-			Method:         entry.method,
-			Result:         targetPtr,
-			Error:          &Error{},
-			ExpectedStatus: entry.okStatus,
-			Data:           postData,
-		}
-
-		return r
-	} else {
-		panic(fmt.Sprintf("No api entry found for %v ", apiEntry))
+//Creates a new request object for the api entry and the target struct where the response for the sever will be decoded.
+//The arguments are matched with the url by name when running, the built-in entries go through the req* functions instead
+func (p Pipeline) newResquest(apiEntry string, targetPtr interface{}, postData interface{}, args ...urlArg) (*Request, error) {
+	entry, ok := lookupEntry(apiEntry)
+	if !ok {
+		return nil, fmt.Errorf("%w: %v", ErrUnknownEntry, apiEntry)
+	}
+	path, err := expandUrl(entry.urlPath, args)
+	if err != nil {
+		return nil, fmt.Errorf("Wrong arguments for api entry %v: %v", apiEntry, err)
+	}
+	r := &restclient.RequestResponse{
+		Url:            p.BaseUrl + path,
+		Method:         entry.method,
+		Result:         targetPtr,
+		Error:          &Error{},
+		ExpectedStatus: entry.okStatus,
+		Data:           postData,
 	}
+	//the secret arguments never reach the logs
+	redacted, err := expandUrl(redactTemplate(entry.urlPath), publicArgs(args))
+	if err != nil {
+		return nil, err
+	}
+	return &Request{RequestResponse: r, Entry: apiEntry, PathParams: describeArgs(args), Context: p.context(), redacted: p.BaseUrl + redacted}, nil
 }
 
 //Executes the request against the client
-func (p Pipeline) do(req *restclient.RequestResponse, handler func(int, restclient.RequestResponse) error) (status int, err error) {
-	p.authenticator(req)
-	log.Printf("Request %v", req)
-	status, err = p.clientMaker().Do(req)
+func (p Pipeline) do(req *Request, handler func(int, restclient.RequestResponse) error) (status int, err error) {
+	start := time.Now()
+	defer func() {
+		p.logRequest(req, status, time.Since(start), err)
+	}()
+	if !p.Supports(req.Entry) {
+		return 0, unsupported(req.Entry)
+	}
+	status, err = p.chain()(req)
 	if err != nil {
 		if err == restclient.UnexpectedStatus {
-			err = handler(status, *req)
+			if err = p.learn(req, status); err == nil {
+				//the errors may quote the url, keep the secrets out
+				resp := *req.RequestResponse
+				resp.Url = loggedUrl(req)
+				err = handler(status, resp)
+			}
 		}
 		return
 	}
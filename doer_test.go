@@ -1,35 +1,36 @@
 package pipeline
 
 import (
+	"errors"
 	"fmt"
 	"testing"
 )
 
 func TestDefaultErrorHandler(t *testing.T) {
 	var alive Alive
-	r := Pipeline{}.newResquest(API_ALIVE, &alive, nil)
-	err := defaultErrorHandler()(404, *r)
+	r, _ := Pipeline{}.reqAlive(&alive)
+	err := defaultErrorHandler()(404, *r.RequestResponse)
 
 	if err.Error() != fmt.Sprintf(ERR_404, apiEntries[API_ALIVE].urlPath) {
 		t.Error("Default 404 not handled")
 	}
 
-	err = defaultErrorHandler()(401, *r)
+	err = defaultErrorHandler()(401, *r.RequestResponse)
 	if err.Error() != ERR_401 {
 		t.Error("Default 401 not handled")
 	}
 
-	err = defaultErrorHandler()(500, *r)
+	err = defaultErrorHandler()(500, *r.RequestResponse)
 	if err.Error() != fmt.Sprintf(ERR_500, " from "+apiEntries[API_ALIVE].urlPath) {
 		t.Error("Default 500 not handled")
 	}
 
 	r.Error.(*Error).Description = "error"
-	err = defaultErrorHandler()(500, *r)
+	err = defaultErrorHandler()(500, *r.RequestResponse)
 	if err.Error() != fmt.Sprintf(ERR_500, "error") {
 		t.Error("Default 500 with desc not handled")
 	}
-	err = defaultErrorHandler()(501, *r)
+	err = defaultErrorHandler()(501, *r.RequestResponse)
 	if err.Error() != fmt.Sprintf(ERR_DEFAULT, 501) {
 		t.Error("Default 500 with desc not handled")
 	}
@@ -37,28 +38,27 @@ func TestDefaultErrorHandler(t *testing.T) {
 
 func TestCustomErrorHandler(t *testing.T) {
 	var alive Alive
-	r := Pipeline{}.newResquest(API_ALIVE, &alive, nil)
+	r, _ := Pipeline{}.reqAlive(&alive)
 	handler := errorHandler(map[int]string{404: "couldnt find it"})
-	err := handler(404, *r)
+	err := handler(404, *r.RequestResponse)
 	if err.Error() != "couldnt find it" {
 		t.Error("custom 404 not handled")
 	}
 }
 
 func TestNewRequestUnknownEntry(t *testing.T) {
-	defer func() {
-		if r := recover(); r == nil {
-			t.Error("Not panicked with unknown api entry")
-		}
-	}()
 	var alive Alive
-	Pipeline{}.newResquest("unknown", &alive, nil)
-
+	if _, err := (Pipeline{}).newResquest("unknown", &alive, nil); !errors.Is(err, ErrUnknownEntry) {
+		t.Errorf("Unknown api entry not reported %v", err)
+	}
+	if _, err := (Pipeline{}).newResquest(API_JOB, &alive, nil, argJob("job")); err == nil {
+		t.Error("Missing argument not reported")
+	}
 }
 
 func TestNewRequestBaseUrl(t *testing.T) {
 	var alive Alive
-	r := Pipeline{BaseUrl: "google.com/"}.newResquest(API_ALIVE, &alive, nil)
+	r, _ := Pipeline{BaseUrl: "google.com/"}.reqAlive(&alive)
 	if r.Url != "google.com/alive" {
 		t.Error("basePath not set")
 	}
@@ -67,7 +67,7 @@ func TestNewRequestBaseUrl(t *testing.T) {
 
 func TestNewRequestPostData(t *testing.T) {
 	var alive Alive
-	r := Pipeline{BaseUrl: "google.com/"}.newResquest(API_ALIVE, &alive, "data")
+	r, _ := Pipeline{BaseUrl: "google.com/"}.newResquest(API_ALIVE, &alive, "data")
 	if r.Data != "data" {
 		t.Error("post data not set")
 	}
@@ -78,7 +78,7 @@ func TestNewRequestPostData(t *testing.T) {
 func TestDoReq(t *testing.T) {
 	var alive Alive
 	pipeline := createPipeline(emptyClientMock)
-	r := pipeline.newResquest(API_ALIVE, &alive, nil)
+	r, _ := pipeline.reqAlive(&alive)
 	if r.Url != "base/alive" {
 		t.Errorf("Alive path set to %v", r.Url)
 	}
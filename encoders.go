@@ -171,5 +171,4 @@ func (d WriterDecoder) Decode(v interface{}) error {
 	default:
 		return errors.New("Writer decoder only admits io.Writer interface")
 	}
-	return nil
 }
@@ -0,0 +1,107 @@
+package pipeline
+
+import (
+	"errors"
+	"fmt"
+	"sort"
+	"strings"
+	"sync"
+)
+
+//Error messages
+var ErrUnknownEntry = errors.New("No api entry found")
+
+//Guards apiEntries against concurrent registrations
+var entriesMutex sync.RWMutex
+
+//Names of the registered entries
+var customEntries = make(map[string]bool)
+
+//Describes an api entry provided by an extension of the framework
+type Endpoint struct {
+	Path       string //Template relative to the base url, placeholders are written {name}, e.g. "ext/items/{id}?full={full}"
+	Method     string //Http method, GET by default
+	OkStatus   int    //Status of a successful response, 200 by default
+	Idempotent bool   //The request can be repeated, e.g. when retrying
+}
+
+//Returns the api entry with the given name
+func lookupEntry(name string) (entry apiEntry, ok bool) {
+	entriesMutex.RLock()
+	defer entriesMutex.RUnlock()
+	entry, ok = apiEntries[name]
+	return
+}
+
+//Registers a custom api entry so it can be used with Call. The built-in
+//entries can't be replaced
+func RegisterEntry(name string, endpoint Endpoint) error {
+	if name == "" {
+		return errors.New("Api entry name is required")
+	}
+	if endpoint.Path == "" || strings.HasPrefix(endpoint.Path, "/") {
+		return fmt.Errorf("Invalid path %q for api entry %v, it must be relative to the base url", endpoint.Path, name)
+	}
+	//check the template
+	if _, err := expandUrl(endpoint.Path, placeholderArgs(endpoint.Path)); err != nil {
+		return fmt.Errorf("Invalid path %q for api entry %v: %v", endpoint.Path, name, err)
+	}
+	if endpoint.Method == "" {
+		endpoint.Method = "GET"
+	}
+	if endpoint.OkStatus == 0 {
+		endpoint.OkStatus = 200
+	}
+	entriesMutex.Lock()
+	defer entriesMutex.Unlock()
+	if _, ok := apiEntries[name]; ok && !customEntries[name] {
+		return fmt.Errorf("Api entry %v is built in", name)
+	}
+	apiEntries[name] = apiEntry{
+		urlPath:    endpoint.Path,
+		method:     strings.ToUpper(endpoint.Method),
+		okStatus:   endpoint.OkStatus,
+		idempotent: endpoint.Idempotent,
+	}
+	customEntries[name] = true
+	return nil
+}
+
+//Removes a custom api entry
+func UnregisterEntry(name string) {
+	entriesMutex.Lock()
+	defer entriesMutex.Unlock()
+	if customEntries[name] {
+		delete(apiEntries, name)
+		delete(customEntries, name)
+	}
+}
+
+//Dummy arguments for every placeholder of the template
+func placeholderArgs(template string) (args []urlArg) {
+	for _, name := range templateArgs(template) {
+		args = append(args, urlArg{name, name})
+	}
+	return
+}
+
+//Calls an api entry, usually a registered one, through the same path as
+//the built-in calls: middlewares, signing, logging and error handling. The
+//response is decoded into result, which may be nil, and data is sent as
+//the request body. args holds the values of the placeholders of the path
+func (p Pipeline) Call(name string, args map[string]string, data interface{}, result interface{}) (status int, err error) {
+	names := make([]string, 0, len(args))
+	for argName := range args {
+		names = append(names, argName)
+	}
+	sort.Strings(names)
+	urlArgs := make([]urlArg, 0, len(args))
+	for _, argName := range names {
+		urlArgs = append(urlArgs, urlArg{argName, args[argName]})
+	}
+	req, err := p.newResquest(name, result, data, urlArgs...)
+	if err != nil {
+		return
+	}
+	return p.do(req, defaultErrorHandler())
+}
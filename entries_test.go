@@ -0,0 +1,142 @@
+package pipeline
+
+import (
+	"errors"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/capitancambio/restclient"
+)
+
+type extItem struct {
+	Id string `xml:"id,attr"`
+}
+
+func TestRegisterEntryCall(t *testing.T) {
+	if err := RegisterEntry("ext-item", Endpoint{Path: "ext/items/{id}?full={full}"}); err != nil {
+		t.Fatalf("Unexpected error %v", err)
+	}
+	defer UnregisterEntry("ext-item")
+	var url string
+	routes := map[string]mockHandler{
+		"GET ext/items": func(rr *restclient.RequestResponse) (string, int) {
+			url = rr.Url
+			return `<item id="a b"/>`, 200
+		},
+	}
+	pipeline := createPipeline(routedClientMock(routes, &mockRecorder{}))
+	pipeline.SetCredentials("cli", "shhh")
+	var params map[string]string
+	pipeline.Use(func(next Handler) Handler {
+		return func(req *Request) (int, error) {
+			params = req.PathParams
+			return next(req)
+		}
+	})
+	item := extItem{}
+	status, err := pipeline.Call("ext-item", map[string]string{"id": "a b", "full": "true"}, nil, &item)
+	if err != nil {
+		t.Fatalf("Unexpected error %v", err)
+	}
+	if status != 200 || item.Id != "a b" {
+		t.Errorf("Wrong response %v %v", status, item)
+	}
+	if !strings.HasPrefix(url, "base/ext/items/a%20b?full=true&authid=cli") || !strings.Contains(url, "&sign=") {
+		t.Errorf("Wrong url %v", url)
+	}
+	if params["id"] != "a b" || params["full"] != "true" {
+		t.Errorf("Wrong params %v", params)
+	}
+}
+
+func TestRegisterEntryErrors(t *testing.T) {
+	tests := map[string]Endpoint{
+		API_ALIVE:   {Path: "other"},
+		"empty":     {Path: ""},
+		"absolute":  {Path: "/ext/items"},
+		"malformed": {Path: "ext/{id}/{id}"},
+	}
+	for name, endpoint := range tests {
+		if err := RegisterEntry(name, endpoint); err == nil {
+			UnregisterEntry(name)
+			t.Errorf("%v registered", name)
+		}
+	}
+	if err := RegisterEntry("", Endpoint{Path: "ext"}); err == nil {
+		t.Errorf("Entry without name registered")
+	}
+	//built-ins are kept
+	UnregisterEntry(API_ALIVE)
+	if entry, ok := lookupEntry(API_ALIVE); !ok || entry.urlPath != "alive" {
+		t.Errorf("Built-in entry changed %v", entry)
+	}
+}
+
+func TestCallUnknownEntry(t *testing.T) {
+	recorder := &mockRecorder{}
+	pipeline := createPipeline(routedClientMock(map[string]mockHandler{}, recorder))
+	if _, err := pipeline.Call("ext-unknown", nil, nil, nil); !errors.Is(err, ErrUnknownEntry) {
+		t.Errorf("Unknown entry not reported %v", err)
+	}
+	RegisterEntry("ext-args", Endpoint{Path: "ext/{id}"})
+	defer UnregisterEntry("ext-args")
+	if _, err := pipeline.Call("ext-args", map[string]string{"other": "1"}, nil, nil); err == nil {
+		t.Errorf("Wrong arguments not reported")
+	}
+	if n := recorder.count(""); n != 0 {
+		t.Errorf(T_STRING, "requests", 0, n)
+	}
+}
+
+func TestCallCustomEntryErrors(t *testing.T) {
+	RegisterEntry("ext-delete", Endpoint{Path: "ext/items/{id}", Method: "delete", OkStatus: 204})
+	defer UnregisterEntry("ext-delete")
+	recorder := &mockRecorder{}
+	pipeline := createPipeline(routedClientMock(map[string]mockHandler{
+		"DELETE ext/items/1": xmlRoute("", 204),
+	}, recorder))
+	if _, err := pipeline.Call("ext-delete", map[string]string{"id": "1"}, nil, nil); err != nil {
+		t.Errorf("Unexpected error %v", err)
+	}
+	_, err := pipeline.Call("ext-delete", map[string]string{"id": "2"}, nil, nil)
+	if err == nil || !strings.Contains(err.Error(), "ext/items/2") {
+		t.Errorf("Not found not reported %v", err)
+	}
+}
+
+func TestCallRetriesIdempotentEntry(t *testing.T) {
+	RegisterEntry("ext-status", Endpoint{Path: "ext/status", Idempotent: true})
+	RegisterEntry("ext-action", Endpoint{Path: "ext/action", Method: "POST"})
+	defer UnregisterEntry("ext-status")
+	defer UnregisterEntry("ext-action")
+	recorder := &mockRecorder{}
+	pipeline := createPipeline(routedClientMock(map[string]mockHandler{
+		"GET ext/status":  xmlRoute("", 503),
+		"POST ext/action": xmlRoute("", 503),
+	}, recorder))
+	pipeline.Use(RetryPolicy{Attempts: 2, Backoff: time.Millisecond}.Middleware())
+	pipeline.Call("ext-status", nil, nil, nil)
+	if n := recorder.count("GET ext/status"); n != 2 {
+		t.Errorf(T_STRING, "attempts", 2, n)
+	}
+	pipeline.Call("ext-action", nil, nil, nil)
+	if n := recorder.count("POST ext/action"); n != 1 {
+		t.Errorf(T_STRING, "attempts", 1, n)
+	}
+}
+
+func TestUnregisterEntry(t *testing.T) {
+	RegisterEntry("ext-tmp", Endpoint{Path: "ext/tmp"})
+	//replacing a custom entry is allowed
+	if err := RegisterEntry("ext-tmp", Endpoint{Path: "ext/tmp2"}); err != nil {
+		t.Errorf("Unexpected error %v", err)
+	}
+	if entry, _ := lookupEntry("ext-tmp"); entry.urlPath != "ext/tmp2" || entry.method != "GET" || entry.okStatus != 200 {
+		t.Errorf("Wrong entry %v", entry)
+	}
+	UnregisterEntry("ext-tmp")
+	if _, ok := lookupEntry("ext-tmp"); ok {
+		t.Errorf("Entry not removed")
+	}
+}
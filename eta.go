@@ -0,0 +1,211 @@
+package pipeline
+
+import (
+	"context"
+	"errors"
+	"sort"
+	"sync"
+	"time"
+)
+
+//Number of jobs the framework runs at the same time by default (org.daisy.pipeline.procs)
+const DEFAULT_WORKERS = 2
+
+//Number of durations kept by a DurationHistory by default
+const DEFAULT_HISTORY_SIZE = 50
+
+//Error messages
+var ErrNotQueued = errors.New("Job is not in the queue")
+
+//Keeps the last durations of the jobs observed by the client
+type DurationHistory struct {
+	mutex     sync.Mutex
+	size      int
+	durations []time.Duration
+	started   map[string]time.Time
+	now       func() time.Time
+}
+
+//Creates a history keeping the last size durations
+func NewDurationHistory(size int) *DurationHistory {
+	if size <= 0 {
+		size = DEFAULT_HISTORY_SIZE
+	}
+	return &DurationHistory{size: size, started: make(map[string]time.Time), now: time.Now}
+}
+
+//Adds a duration to the history
+func (h *DurationHistory) Record(d time.Duration) {
+	h.mutex.Lock()
+	defer h.mutex.Unlock()
+	h.durations = append(h.durations, d)
+	if len(h.durations) > h.size {
+		h.durations = h.durations[len(h.durations)-h.size:]
+	}
+}
+
+//Returns the mean of the recorded durations, ok is false if there are none
+func (h *DurationHistory) Mean() (mean time.Duration, ok bool) {
+	h.mutex.Lock()
+	defer h.mutex.Unlock()
+	if len(h.durations) == 0 {
+		return 0, false
+	}
+	var total time.Duration
+	for _, d := range h.durations {
+		total += d
+	}
+	return total / time.Duration(len(h.durations)), true
+}
+
+//Wraps a JobWatcher callback so the time between the job starting to run
+//and finishing is recorded. next may be nil. The pipelines created by
+//NewPipeline already record the jobs watched or run in batches into their
+//own history, see SetDurationHistory
+func (h *DurationHistory) Observer(next func(JobUpdate)) func(JobUpdate) {
+	return func(u JobUpdate) {
+		h.observe(u.Job)
+		if next != nil {
+			next(u)
+		}
+	}
+}
+
+//Sets the history recording the durations of the jobs watched by a
+//JobWatcher or run by a BatchRunner, nil stops recording. It's shared by
+//the copies of the pipeline
+func (p *Pipeline) SetDurationHistory(history *DurationHistory) {
+	p.history = history
+}
+
+//Returns the history of the job durations, nil if not recording
+func (p Pipeline) DurationHistory() *DurationHistory {
+	return p.history
+}
+
+//Feeds the history with a new state of the job
+func (p Pipeline) observeJob(job Job) {
+	if p.history != nil {
+		p.history.observe(job)
+	}
+}
+
+func (h *DurationHistory) observe(job Job) {
+	h.mutex.Lock()
+	start, running := h.started[job.Id]
+	switch {
+	case job.Status == JOB_RUNNING && !running:
+		h.started[job.Id] = h.now()
+	case Finished(job.Status) && running:
+		delete(h.started, job.Id)
+		h.mutex.Unlock()
+		h.Record(h.now().Sub(start))
+		return
+	}
+	h.mutex.Unlock()
+}
+
+//Estimated start of a queued job
+type QueueEstimate struct {
+	Job      QueueJob      //The job as listed in the queue
+	Position int           //Position in the queue, 0 being the head
+	Ahead    []QueueJob    //Jobs that will run before this one
+	Wait     time.Duration //Estimated time until the job starts
+	Start    time.Time     //Estimated start time
+	Known    bool          //False if no durations were observed so Wait and Start are meaningless
+}
+
+//Estimates when queued jobs will start from the durations observed by the client
+type QueueEstimator struct {
+	Workers  int //Jobs run by the framework at the same time
+	History  *DurationHistory
+	Now      func() time.Time
+	pipeline Pipeline
+}
+
+//Creates a new estimator. If history is nil the one of the pipeline is
+//used, or a new one if the pipeline doesn't record the durations
+func NewQueueEstimator(p Pipeline, history *DurationHistory) *QueueEstimator {
+	if history == nil {
+		history = p.history
+	}
+	if history == nil {
+		history = NewDurationHistory(DEFAULT_HISTORY_SIZE)
+	}
+	return &QueueEstimator{
+		Workers:  DEFAULT_WORKERS,
+		History:  history,
+		Now:      time.Now,
+		pipeline: p,
+	}
+}
+
+//Orders the queue as the server picks the jobs: highest ComputedPriority
+//first and, for equal priorities, the one that waited longest in the current
+//queue, i.e. the highest RelativeTime. The listing order is kept otherwise
+func runOrder(jobs []QueueJob) []QueueJob {
+	ordered := append([]QueueJob(nil), jobs...)
+	sort.SliceStable(ordered, func(i, j int) bool {
+		if ordered[i].ComputedPriority != ordered[j].ComputedPriority {
+			return ordered[i].ComputedPriority > ordered[j].ComputedPriority
+		}
+		return ordered[i].RelativeTime > ordered[j].RelativeTime
+	})
+	return ordered
+}
+
+//Computes the estimate for the job in the given queue. The jobs ahead are
+//the ones run before it, see runOrder. With every worker busy, a worker
+//gets free every mean duration / workers, the job starts once the jobs ahead
+//and itself got one
+func (e *QueueEstimator) estimate(jobs []QueueJob, jobId string) (est QueueEstimate, err error) {
+	jobs = runOrder(jobs)
+	pos := queueIndex(jobs, jobId)
+	if pos < 0 {
+		return est, ErrNotQueued
+	}
+	est.Job = jobs[pos]
+	est.Position = pos
+	est.Ahead = jobs[:pos]
+	mean, ok := e.History.Mean()
+	if !ok {
+		return
+	}
+	workers := e.Workers
+	if workers < 1 {
+		workers = 1
+	}
+	est.Known = true
+	est.Wait = mean * time.Duration(pos+1) / time.Duration(workers)
+	est.Start = e.Now().Add(est.Wait)
+	return
+}
+
+//Estimates when the job will start
+func (e *QueueEstimator) Estimate(jobId string) (est QueueEstimate, err error) {
+	jobs, err := e.pipeline.Queue()
+	if err != nil {
+		return
+	}
+	return e.estimate(jobs, jobId)
+}
+
+//Calls fn with a fresh estimate every interval until the job leaves the queue,
+//which returns nil, or the context is done
+func (e *QueueEstimator) Watch(ctx context.Context, jobId string, interval time.Duration, fn func(QueueEstimate)) error {
+	for {
+		est, err := e.Estimate(jobId)
+		if err == ErrNotQueued {
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+		fn(est)
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-time.After(interval):
+		}
+	}
+}
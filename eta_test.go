@@ -0,0 +1,105 @@
+package pipeline
+
+import (
+	"context"
+	"testing"
+	"time"
+)
+
+func TestDurationHistory(t *testing.T) {
+	h := NewDurationHistory(2)
+	if _, ok := h.Mean(); ok {
+		t.Errorf("Empty history has no mean")
+	}
+	h.Record(time.Hour)
+	h.Record(time.Minute)
+	h.Record(3 * time.Minute)
+	if mean, _ := h.Mean(); mean != 2*time.Minute {
+		t.Errorf(T_STRING, "mean", 2*time.Minute, mean)
+	}
+}
+
+func TestDurationHistoryObserver(t *testing.T) {
+	h := NewDurationHistory(10)
+	now := time.Date(2014, 1, 1, 0, 0, 0, 0, time.UTC)
+	h.now = func() time.Time { return now }
+	calls := 0
+	observer := h.Observer(func(JobUpdate) { calls++ })
+	observer(JobUpdate{Job: Job{Id: "a", Status: JOB_IDLE}})
+	observer(JobUpdate{Job: Job{Id: "a", Status: JOB_RUNNING}})
+	now = now.Add(10 * time.Minute)
+	observer(JobUpdate{Job: Job{Id: "a", Status: JOB_RUNNING}})
+	observer(JobUpdate{Job: Job{Id: "a", Status: JOB_DONE}})
+	//never seen running
+	observer(JobUpdate{Job: Job{Id: "b", Status: JOB_DONE}})
+	if mean, ok := h.Mean(); !ok || mean != 10*time.Minute {
+		t.Errorf(T_STRING, "mean", 10*time.Minute, mean)
+	}
+	if calls != 5 {
+		t.Errorf(T_STRING, "calls", 5, calls)
+	}
+}
+
+func TestQueueEstimate(t *testing.T) {
+	q := &mockQueue{ids: []string{"a", "b", "c", "d"}}
+	estimator := NewQueueEstimator(queuePipeline(q), nil)
+	est, err := estimator.Estimate("c")
+	if err != nil {
+		t.Fatalf("Unexpected error %v", err)
+	}
+	if est.Position != 2 || len(est.Ahead) != 2 || est.Known {
+		t.Errorf("Wrong estimate %+v", est)
+	}
+	now := time.Date(2014, 1, 1, 0, 0, 0, 0, time.UTC)
+	estimator.Now = func() time.Time { return now }
+	estimator.History.Record(10 * time.Minute)
+	est, _ = estimator.Estimate("c")
+	if !est.Known || est.Wait != 15*time.Minute || !est.Start.Equal(now.Add(15*time.Minute)) {
+		t.Errorf("Wrong estimate %+v", est)
+	}
+	if _, err = estimator.Estimate("x"); err != ErrNotQueued {
+		t.Errorf(T_STRING, "error", ErrNotQueued, err)
+	}
+}
+
+func TestQueueEstimateRunOrder(t *testing.T) {
+	jobs := []QueueJob{
+		{Id: "a", ComputedPriority: 0.2, RelativeTime: 0.9},
+		{Id: "b", ComputedPriority: 0.5, RelativeTime: 0.1},
+		{Id: "c", ComputedPriority: 0.2, RelativeTime: 1},
+		{Id: "d", ComputedPriority: 0.5, RelativeTime: 0.6},
+	}
+	estimator := NewQueueEstimator(Pipeline{}, nil)
+	estimator.History.Record(10 * time.Minute)
+	est, err := estimator.estimate(jobs, "a")
+	if err != nil {
+		t.Fatalf("Unexpected error %v", err)
+	}
+	if est.Position != 3 || est.Ahead[0].Id != "d" || est.Ahead[1].Id != "b" || est.Ahead[2].Id != "c" {
+		t.Errorf("Wrong estimate %+v", est)
+	}
+	if est.Wait != 20*time.Minute {
+		t.Errorf(T_STRING, "wait", 20*time.Minute, est.Wait)
+	}
+	//the listed queue is left untouched
+	if jobs[0].Id != "a" {
+		t.Errorf("Queue reordered %v", jobs)
+	}
+}
+
+func TestQueueEstimateWatch(t *testing.T) {
+	q := &mockQueue{ids: []string{"a", "b"}}
+	estimator := NewQueueEstimator(queuePipeline(q), nil)
+	var positions []int
+	err := estimator.Watch(context.Background(), "b", time.Millisecond, func(est QueueEstimate) {
+		positions = append(positions, est.Position)
+		//the head of the queue starts running
+		q.ids = q.ids[1:]
+	})
+	if err != nil {
+		t.Errorf("Unexpected error %v", err)
+	}
+	if len(positions) != 2 || positions[0] != 1 || positions[1] != 0 {
+		t.Errorf("Wrong positions %v", positions)
+	}
+}
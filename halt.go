@@ -0,0 +1,116 @@
+package pipeline
+
+import (
+	"context"
+	"errors"
+	"sync/atomic"
+	"time"
+)
+
+//Default time between checks while draining
+const DEFAULT_DRAIN_INTERVAL = 5 * time.Second
+
+//Error messages
+var ErrDraining = errors.New("Pipeline is draining, no new jobs are accepted")
+
+type drainFlag struct {
+	on int32
+}
+
+//Tells whether this pipeline (or any copy of it) is draining
+func (p Pipeline) Draining() bool {
+	return p.drain != nil && atomic.LoadInt32(&p.drain.on) == 1
+}
+
+//Stops sending new job requests, JobRequest returns ErrDraining until
+//StopDrain is called. Copies of the pipeline made afterwards drain too
+func (p *Pipeline) StartDrain() {
+	if p.drain == nil {
+		p.drain = &drainFlag{}
+	}
+	atomic.StoreInt32(&p.drain.on, 1)
+}
+
+//Accepts job requests again
+func (p Pipeline) StopDrain() {
+	if p.drain != nil {
+		atomic.StoreInt32(&p.drain.on, 0)
+	}
+}
+
+//Tells whether the queue is empty and none of the jobs is running
+func (p Pipeline) Idle() (idle bool, err error) {
+	queue, err := p.Queue()
+	if err != nil || len(queue) > 0 {
+		return
+	}
+	jobs, err := p.Jobs()
+	if err != nil {
+		return
+	}
+	for _, job := range jobs.Jobs {
+		if job.Status == JOB_RUNNING {
+			return false, nil
+		}
+	}
+	return true, nil
+}
+
+//Stops submitting jobs, waits until the server is idle and halts it. The
+//context bounds the wait and the requests; if the server isn't halted the
+//drain is lifted so the client keeps working
+func (p *Pipeline) DrainAndHalt(ctx context.Context, key string, interval time.Duration) (err error) {
+	if interval <= 0 {
+		interval = DEFAULT_DRAIN_INTERVAL
+	}
+	p.StartDrain()
+	defer func() {
+		if err != nil {
+			p.StopDrain()
+		}
+	}()
+	live := p.WithContext(ctx)
+	for {
+		idle, err := live.Idle()
+		if err != nil {
+			return err
+		}
+		if idle {
+			break
+		}
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-time.After(interval):
+		}
+	}
+	return live.Halt(key)
+}
+
+//Waits until the server can't be connected to, meaning that it went down.
+//Any answer, errors included, means that it's still up
+func (p Pipeline) WaitDown(ctx context.Context, interval time.Duration) error {
+	if interval <= 0 {
+		interval = DEFAULT_DRAIN_INTERVAL
+	}
+	live := p.WithContext(ctx)
+	for {
+		alive := Alive{}
+		req, err := live.reqAlive(&alive)
+		if err != nil {
+			return err
+		}
+		status, err := live.do(req, defaultErrorHandler())
+		if ctx.Err() != nil {
+			return ctx.Err()
+		}
+		if err != nil && status == 0 && !errors.Is(err, ErrUnsupported) {
+			return nil
+		}
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-time.After(interval):
+		}
+	}
+}
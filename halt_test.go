@@ -0,0 +1,151 @@
+package pipeline
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/capitancambio/restclient"
+)
+
+func TestDrain(t *testing.T) {
+	pipeline, _ := NewPipeline("http://localhost:8181/ws/")
+	pipeline.clientMaker = xmlClientMock(jobCreationOk, 201)
+	copied := *pipeline
+	pipeline.StartDrain()
+	if _, err := copied.JobRequest(JobRequest{}, nil); err != ErrDraining {
+		t.Errorf(T_STRING, "error", ErrDraining, err)
+	}
+	pipeline.StopDrain()
+	if _, err := copied.JobRequest(JobRequest{}, nil); err != nil {
+		t.Errorf("Unexpected error %v", err)
+	}
+	var zero Pipeline
+	if zero.Draining() {
+		t.Errorf("Zero pipeline should not drain")
+	}
+}
+
+func TestDrainAndHalt(t *testing.T) {
+	q := &mockQueue{ids: []string{"job-id-03"}}
+	running := jobsXml
+	recorder := &mockRecorder{}
+	pipeline := createPipeline(routedClientMock(map[string]mockHandler{
+		"GET queue": func(*restclient.RequestResponse) (string, int) {
+			xml := q.xml()
+			q.ids = nil
+			return xml, 200
+		},
+		"GET jobs": func(*restclient.RequestResponse) (string, int) {
+			xml := running
+			running = strings.Replace(running, "RUNNING", "DONE", -1)
+			return xml, 200
+		},
+		"GET admin/halt/key": xmlRoute("", 204),
+	}, recorder))
+	err := pipeline.DrainAndHalt(context.Background(), "key", time.Millisecond)
+	if err != nil {
+		t.Fatalf("Unexpected error %v", err)
+	}
+	if !pipeline.Draining() {
+		t.Errorf("Pipeline should still be draining")
+	}
+	if recorder.count("GET queue") != 3 || recorder.count("GET jobs") != 2 || recorder.count("GET admin/halt/key") != 1 {
+		t.Errorf("Wrong calls %v", recorder.calls)
+	}
+}
+
+func TestDrainAndHaltTimeout(t *testing.T) {
+	q := &mockQueue{ids: []string{"job-id-03"}}
+	pipeline := createPipeline(routedClientMock(map[string]mockHandler{
+		"GET queue": func(*restclient.RequestResponse) (string, int) {
+			return q.xml(), 200
+		},
+	}, &mockRecorder{}))
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Millisecond)
+	defer cancel()
+	if err := pipeline.DrainAndHalt(ctx, "key", time.Millisecond); err != context.DeadlineExceeded {
+		t.Errorf(T_STRING, "error", context.DeadlineExceeded, err)
+	}
+	if pipeline.Draining() {
+		t.Errorf("Drain should be lifted when the server isn't halted")
+	}
+}
+
+func TestWaitDown(t *testing.T) {
+	calls := 0
+	pipeline := createPipeline(routedClientMock(map[string]mockHandler{
+		"GET alive": func(*restclient.RequestResponse) (string, int) {
+			calls++
+			switch calls {
+			case 1:
+				return aliveXml, 200
+			case 2:
+				return "", 500
+			case 3:
+				return "", 401
+			}
+			//no connection
+			return "", 0
+		},
+	}, &mockRecorder{}))
+	if err := pipeline.WaitDown(context.Background(), time.Millisecond); err != nil {
+		t.Errorf("Unexpected error %v", err)
+	}
+	if calls != 4 {
+		t.Errorf(T_STRING, "calls", 4, calls)
+	}
+}
+
+func TestWaitDownConnectionRefused(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte(aliveXml))
+	}))
+	pipeline, err := NewPipeline(server.URL)
+	if err != nil {
+		t.Fatalf("Unexpected error %v", err)
+	}
+	server.Close()
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	if err := pipeline.WaitDown(ctx, time.Millisecond); err != nil {
+		t.Errorf("Unexpected error %v", err)
+	}
+}
+
+func TestHaltRequestsUseContext(t *testing.T) {
+	block := make(chan struct{})
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		select {
+		case <-block:
+		case <-r.Context().Done():
+		}
+	}))
+	defer server.Close()
+	defer close(block)
+	pipeline, err := NewPipeline(server.URL)
+	if err != nil {
+		t.Fatalf("Unexpected error %v", err)
+	}
+	for name, call := range map[string]func(context.Context) error{
+		"DrainAndHalt": func(ctx context.Context) error { return pipeline.DrainAndHalt(ctx, "key", time.Millisecond) },
+		"WaitDown":     func(ctx context.Context) error { return pipeline.WaitDown(ctx, time.Millisecond) },
+	} {
+		ctx, cancel := context.WithTimeout(context.Background(), 20*time.Millisecond)
+		done := make(chan error, 1)
+		go func() { done <- call(ctx) }()
+		select {
+		case err := <-done:
+			if !errors.Is(err, context.DeadlineExceeded) {
+				t.Errorf("%v: %v", name, err)
+			}
+		case <-time.After(5 * time.Second):
+			t.Errorf("%v ignored the context", name)
+		}
+		cancel()
+	}
+}
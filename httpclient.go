@@ -0,0 +1,135 @@
+package pipeline
+
+import (
+	"bytes"
+	"context"
+	"encoding/xml"
+	"io"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+
+	"github.com/capitancambio/restclient"
+)
+
+//Rest client on top of net/http. It behaves like restclient.Client but
+//never writes to the standard logger, logging is left to Pipeline
+type httpClient struct {
+	HttpClient      *http.Client
+	EncoderSupplier func(io.Writer) restclient.Encoder
+	DecoderSupplier func(io.Reader) restclient.Decoder
+	ContentType     string
+	ctx             context.Context //cancels the request, optional
+}
+
+//Creates a client using xml encoders
+func newHttpClient(client *http.Client) *httpClient {
+	return &httpClient{
+		HttpClient: client,
+		EncoderSupplier: func(w io.Writer) restclient.Encoder {
+			return xml.NewEncoder(w)
+		},
+		DecoderSupplier: func(r io.Reader) restclient.Decoder {
+			return xml.NewDecoder(r)
+		},
+		ContentType: "application/xml",
+	}
+}
+
+func (c *httpClient) SetDecoderSupplier(fn func(io.Reader) restclient.Decoder) {
+	c.DecoderSupplier = fn
+}
+
+func (c *httpClient) SetEncoderSupplier(fn func(io.Writer) restclient.Encoder) {
+	c.EncoderSupplier = fn
+}
+
+func (c *httpClient) SetContentType(s string) {
+	c.ContentType = s
+}
+
+//Counts the bytes read from the response body
+type countingReader struct {
+	r io.Reader
+	n int64
+}
+
+func (c *countingReader) Read(p []byte) (n int, err error) {
+	n, err = c.r.Read(p)
+	c.n += int64(n)
+	return
+}
+
+//Executes the request, Result is decoded for successful statuses and Error
+//otherwise. If the server doesn't send the length of the response, the bytes
+//read are stored in HttpResponse.ContentLength
+func (c *httpClient) Do(rr *restclient.RequestResponse) (status int, err error) {
+	rr.Method = strings.ToUpper(rr.Method)
+	u, err := url.Parse(rr.Url)
+	if err != nil {
+		return
+	}
+	if rr.Method == "GET" && rr.Params != nil {
+		vals := u.Query()
+		for k, v := range rr.Params {
+			vals.Set(k, v)
+		}
+		u.RawQuery = vals.Encode()
+	}
+	var body io.Reader
+	if rr.Data != nil {
+		buf := &bytes.Buffer{}
+		if err = c.EncoderSupplier(buf).Encode(rr.Data); err != nil {
+			return
+		}
+		body = buf
+	}
+	ctx := c.ctx
+	if ctx == nil {
+		ctx = context.Background()
+	}
+	req, err := http.NewRequestWithContext(ctx, rr.Method, u.String(), body)
+	if err != nil {
+		return
+	}
+	if rr.Data != nil {
+		req.Header.Set("Content-Type", c.ContentType)
+	}
+	if rr.Header != nil {
+		for key, values := range *rr.Header {
+			if len(values) > 0 {
+				req.Header.Set(key, values[0])
+			}
+		}
+	}
+	if req.Header.Get("Accept") == "" {
+		req.Header.Set("Accept", c.ContentType)
+	}
+	rr.Timestamp = time.Now()
+	resp, err := c.HttpClient.Do(req)
+	if err != nil {
+		return
+	}
+	defer resp.Body.Close()
+	status = resp.StatusCode
+	rr.HttpResponse = resp
+	rr.Status = status
+	counter := &countingReader{r: resp.Body}
+	if status < 300 && rr.Result != nil {
+		err = c.DecoderSupplier(counter).Decode(rr.Result)
+	} else if rr.Error != nil {
+		//the body of the error is optional
+		c.DecoderSupplier(counter).Decode(rr.Error)
+	}
+	if resp.ContentLength < 0 {
+		resp.ContentLength = counter.n
+	}
+	if err != nil {
+		return
+	}
+	if rr.ExpectedStatus != 0 && status != rr.ExpectedStatus {
+		return status, restclient.UnexpectedStatus
+	}
+	return
+}
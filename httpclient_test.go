@@ -0,0 +1,111 @@
+package pipeline
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"io"
+	"log"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"testing"
+
+	"github.com/capitancambio/restclient"
+)
+
+func TestHttpClientDo(t *testing.T) {
+	var gotMethod, gotType, gotBody string
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		gotMethod = r.Method
+		gotType = r.Header.Get("Content-Type")
+		body, _ := io.ReadAll(r.Body)
+		gotBody = string(body)
+		w.WriteHeader(201)
+		w.Write([]byte(aliveXml))
+	}))
+	defer server.Close()
+
+	var alive Alive
+	rr := &restclient.RequestResponse{
+		Url:            server.URL + "/jobs",
+		Method:         "post",
+		Data:           &Client{Id: "cli"},
+		Result:         &alive,
+		Error:          &Error{},
+		ExpectedStatus: 201,
+	}
+	status, err := newHttpClient(server.Client()).Do(rr)
+	if err != nil {
+		t.Fatalf("Unexpected error %v", err)
+	}
+	if status != 201 || gotMethod != "POST" || gotType != "application/xml" {
+		t.Errorf("Wrong request %v %v %v", status, gotMethod, gotType)
+	}
+	if !bytes.Contains([]byte(gotBody), []byte(`id="cli"`)) {
+		t.Errorf("Data not encoded %v", gotBody)
+	}
+	if alive.Version != "1.6" {
+		t.Errorf(T_STRING, "version", "1.6", alive.Version)
+	}
+	if rr.HttpResponse.ContentLength != int64(len(aliveXml)) {
+		t.Errorf(T_STRING, "length", len(aliveXml), rr.HttpResponse.ContentLength)
+	}
+}
+
+func TestHttpClientUnexpectedStatus(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(500)
+		w.Write([]byte(errorXml[1:]))
+	}))
+	defer server.Close()
+
+	var alive Alive
+	rr := &restclient.RequestResponse{
+		Url:            server.URL + "/alive",
+		Method:         "GET",
+		Result:         &alive,
+		Error:          &Error{},
+		ExpectedStatus: 200,
+	}
+	status, err := newHttpClient(server.Client()).Do(rr)
+	if err != restclient.UnexpectedStatus || status != 500 {
+		t.Errorf("Expected unexpected status, got %v %v", status, err)
+	}
+	if desc := rr.Error.(*Error).Description; desc != "Error while acquiring jobs" {
+		t.Errorf(T_STRING, "description", "Error while acquiring jobs", desc)
+	}
+}
+
+func TestHttpClientSilent(t *testing.T) {
+	var out bytes.Buffer
+	log.SetOutput(&out)
+	defer log.SetOutput(os.Stderr)
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(404)
+	}))
+	defer server.Close()
+
+	pipeline, _ := NewPipeline(server.URL, WithHttpClient(server.Client()))
+	pipeline.NewClient(Client{Id: "cli", Role: ROLE_CLIENTAPP, Secret: "supersecret"})
+	if out.Len() != 0 {
+		t.Errorf("Standard logger used: %v", out.String())
+	}
+}
+
+func TestHttpClientContext(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte(aliveXml))
+	}))
+	defer server.Close()
+
+	pipeline, _ := NewPipeline(server.URL, WithHttpClient(server.Client()))
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	if _, err := pipeline.WithContext(ctx).Alive(); err == nil || !errors.Is(err, context.Canceled) {
+		t.Errorf("Cancelled request not stopped %v", err)
+	}
+	if _, err := pipeline.Alive(); err != nil {
+		t.Errorf("Unexpected error %v", err)
+	}
+}
@@ -0,0 +1,215 @@
+package pipeline
+
+import (
+	"fmt"
+	"net/url"
+	"path"
+	"sort"
+	"strings"
+)
+
+//Fields the job list can be sorted by
+type JobSortKey string
+
+const (
+	SORT_NONE     JobSortKey = ""
+	SORT_ID       JobSortKey = "id"
+	SORT_NICENAME JobSortKey = "nicename"
+	SORT_STATUS   JobSortKey = "status"
+	SORT_PRIORITY JobSortKey = "priority"
+	SORT_BATCH    JobSortKey = "batch"
+	SORT_SCRIPT   JobSortKey = "script"
+)
+
+//Criteria to select jobs from the job list, empty fields match everything
+type JobQuery struct {
+	Statuses   []string   //Any of the statuses
+	BatchId    string     //Exact batch id
+	ScriptId   string     //Exact script id
+	Nicename   string     //Glob pattern as in path.Match, e.g. "dtbook-*"
+	Priority   Priority   //Job priority
+	SortBy     JobSortKey //Sort key, the server order is kept if empty
+	Descending bool       //Reverse the sort order
+}
+
+//Tells whether the job matches the query
+func (q JobQuery) Match(job Job) bool {
+	if len(q.Statuses) > 0 {
+		found := false
+		for _, status := range q.Statuses {
+			if strings.EqualFold(status, job.Status) {
+				found = true
+				break
+			}
+		}
+		if !found {
+			return false
+		}
+	}
+	if q.BatchId != "" && q.BatchId != job.BatchId {
+		return false
+	}
+	if q.ScriptId != "" && q.ScriptId != job.Script.Id {
+		return false
+	}
+	if q.Priority != "" && !strings.EqualFold(string(q.Priority), string(job.Priority)) {
+		return false
+	}
+	if q.Nicename != "" {
+		if ok, err := path.Match(q.Nicename, job.Nicename); err != nil || !ok {
+			return false
+		}
+	}
+	return true
+}
+
+//Validates the query
+func (q JobQuery) Validate() error {
+	if _, err := path.Match(q.Nicename, ""); err != nil {
+		return fmt.Errorf("Invalid nicename pattern %q: %v", q.Nicename, err)
+	}
+	switch q.SortBy {
+	case SORT_NONE, SORT_ID, SORT_NICENAME, SORT_STATUS, SORT_PRIORITY, SORT_BATCH, SORT_SCRIPT:
+		return nil
+	}
+	return fmt.Errorf("Unknown sort key %q", q.SortBy)
+}
+
+//Returns the jobs matching the query in the requested order
+func (q JobQuery) Apply(jobs []Job) []Job {
+	res := []Job{}
+	for _, job := range jobs {
+		if q.Match(job) {
+			res = append(res, job)
+		}
+	}
+	if q.SortBy != SORT_NONE {
+		less := jobLess(q.SortBy)
+		sort.SliceStable(res, func(i, j int) bool {
+			if q.Descending {
+				return less(res[j], res[i])
+			}
+			return less(res[i], res[j])
+		})
+	}
+	return res
+}
+
+//Query parameters understood by the servers supporting them
+func (q JobQuery) values() url.Values {
+	values := url.Values{}
+	for _, status := range q.Statuses {
+		values.Add("status", status)
+	}
+	if q.BatchId != "" {
+		values.Set("batchId", q.BatchId)
+	}
+	if q.ScriptId != "" {
+		values.Set("script", q.ScriptId)
+	}
+	if q.Priority != "" {
+		values.Set("priority", string(q.Priority))
+	}
+	return values
+}
+
+func jobLess(key JobSortKey) func(a, b Job) bool {
+	switch key {
+	case SORT_NICENAME:
+		return func(a, b Job) bool { return a.Nicename < b.Nicename }
+	case SORT_STATUS:
+		return func(a, b Job) bool { return a.Status < b.Status }
+	case SORT_PRIORITY:
+		return func(a, b Job) bool { return a.Priority.Rank() < b.Priority.Rank() }
+	case SORT_BATCH:
+		return func(a, b Job) bool { return a.BatchId < b.BatchId }
+	case SORT_SCRIPT:
+		return func(a, b Job) bool { return a.Script.Id < b.Script.Id }
+	}
+	return func(a, b Job) bool { return a.Id < b.Id }
+}
+
+//Page of a job list
+type JobPage struct {
+	Jobs     []Job //Jobs in the page
+	Page     int   //Page number, starting at 0
+	PageSize int   //Maximum number of jobs per page
+	Total    int   //Total number of jobs in the list
+}
+
+//Number of pages of the list
+func (p JobPage) Pages() int {
+	if p.PageSize <= 0 {
+		return 1
+	}
+	return (p.Total + p.PageSize - 1) / p.PageSize
+}
+
+//Tells whether there are pages after this one
+func (p JobPage) HasNext() bool {
+	return p.Page+1 < p.Pages()
+}
+
+//Returns the given page of the list, a page size of 0 or less returns the whole list
+func Paginate(jobs []Job, page, pageSize int) JobPage {
+	res := JobPage{Page: page, PageSize: pageSize, Total: len(jobs)}
+	if pageSize <= 0 {
+		res.Jobs = jobs
+		return res
+	}
+	from := page * pageSize
+	if page < 0 || from >= len(jobs) {
+		res.Jobs = []Job{}
+		return res
+	}
+	to := from + pageSize
+	if to > len(jobs) {
+		to = len(jobs)
+	}
+	res.Jobs = jobs[from:to]
+	return res
+}
+
+//Sends the job queries to servers from the given version on as query
+//parameters. No released framework is known to filter the job list, so by
+//default, with the zero version, the jobs are only filtered on the client
+func (p *Pipeline) SetJobQueryVersion(since Version) {
+	p.jobQuerySince = since
+}
+
+//Returns the jobs matching the query. The query is sent to the server
+//when SetJobQueryVersion says it supports it, the result is filtered on the
+//client anyway
+func (p Pipeline) QueryJobs(q JobQuery) (jobs []Job, err error) {
+	if err = q.Validate(); err != nil {
+		return
+	}
+	list := Jobs{}
+	req, err := p.reqJobs(&list)
+	if err != nil {
+		return
+	}
+	if !p.jobQuerySince.IsZero() {
+		var version Version
+		if version, err = p.serverVersion(); err != nil {
+			return
+		}
+		if values := q.values(); version.AtLeast(p.jobQuerySince) && len(values) > 0 {
+			req.Url += "?" + values.Encode()
+		}
+	}
+	_, err = p.do(req, defaultErrorHandler())
+	if err != nil {
+		return
+	}
+	return q.Apply(list.Jobs), nil
+}
+
+//Returns a page of the jobs matching the query
+func (p Pipeline) JobsPage(q JobQuery, page, pageSize int) (JobPage, error) {
+	jobs, err := p.QueryJobs(q)
+	if err != nil {
+		return JobPage{}, err
+	}
+	return Paginate(jobs, page, pageSize), nil
+}
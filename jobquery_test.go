@@ -0,0 +1,121 @@
+package pipeline
+
+import (
+	"strings"
+	"testing"
+)
+
+var queryJobs = []Job{
+	{Id: "c", Nicename: "dtbook-1", Status: JOB_DONE, Priority: "low", BatchId: "b1"},
+	{Id: "a", Nicename: "epub-1", Status: JOB_RUNNING, Priority: "high", BatchId: "b1"},
+	{Id: "b", Nicename: "dtbook-2", Status: JOB_ERROR, Priority: "medium", BatchId: "b2"},
+}
+
+func jobIds(jobs []Job) string {
+	ids := []string{}
+	for _, job := range jobs {
+		ids = append(ids, job.Id)
+	}
+	return strings.Join(ids, ",")
+}
+
+func TestJobQueryApply(t *testing.T) {
+	cases := []struct {
+		query JobQuery
+		exp   string
+	}{
+		{JobQuery{}, "c,a,b"},
+		{JobQuery{SortBy: SORT_ID}, "a,b,c"},
+		{JobQuery{SortBy: SORT_PRIORITY, Descending: true}, "a,b,c"},
+		{JobQuery{Statuses: []string{"done", JOB_ERROR}}, "c,b"},
+		{JobQuery{Nicename: "dtbook-*", SortBy: SORT_NICENAME}, "c,b"},
+		{JobQuery{BatchId: "b1", Priority: "HIGH"}, "a"},
+		{JobQuery{ScriptId: "none"}, ""},
+	}
+	for _, c := range cases {
+		if res := jobIds(c.query.Apply(queryJobs)); res != c.exp {
+			t.Errorf(T_STRING, c.query, c.exp, res)
+		}
+	}
+}
+
+func TestJobQueryValidate(t *testing.T) {
+	if err := (JobQuery{Nicename: "["}).Validate(); err == nil {
+		t.Errorf("Expected error not thrown")
+	}
+	if err := (JobQuery{SortBy: "size"}).Validate(); err == nil {
+		t.Errorf("Expected error not thrown")
+	}
+}
+
+func TestPaginate(t *testing.T) {
+	page := Paginate(queryJobs, 0, 2)
+	if jobIds(page.Jobs) != "c,a" || page.Pages() != 2 || !page.HasNext() {
+		t.Errorf("Wrong first page %+v", page)
+	}
+	page = Paginate(queryJobs, 1, 2)
+	if jobIds(page.Jobs) != "b" || page.HasNext() {
+		t.Errorf("Wrong last page %+v", page)
+	}
+	if page = Paginate(queryJobs, 5, 2); len(page.Jobs) != 0 {
+		t.Errorf("Page out of range should be empty %+v", page)
+	}
+	if page = Paginate(queryJobs, 0, 0); len(page.Jobs) != 3 || page.Pages() != 1 {
+		t.Errorf("Wrong unpaginated list %+v", page)
+	}
+}
+
+func TestQueryJobs(t *testing.T) {
+	pipeline := createPipeline(xmlClientMock(jobsXml, 200))
+	page, err := pipeline.JobsPage(JobQuery{Statuses: []string{JOB_DONE, JOB_RUNNING}}, 0, 1)
+	if err != nil {
+		t.Errorf("Unexpected error %v", err)
+	}
+	if jobIds(page.Jobs) != "job-id-01" || page.Total != 2 {
+		t.Errorf("Wrong page %+v", page)
+	}
+}
+
+func TestQueryJobsServerSide(t *testing.T) {
+	recorder := &mockRecorder{}
+	pipeline := createPipeline(routedClientMock(map[string]mockHandler{
+		"GET alive": xmlRoute(aliveXml, 200),
+		"GET jobs":  xmlRoute(jobsXml, 200),
+	}, recorder))
+	pipeline.SetJobQueryVersion(Version{Major: 1, Minor: 6})
+	jobs, err := pipeline.QueryJobs(JobQuery{BatchId: "b1"})
+	if err != nil {
+		t.Errorf("Unexpected error %v", err)
+	}
+	if len(jobs) != 0 {
+		t.Errorf(T_STRING, "jobs", 0, len(jobs))
+	}
+	if recorder.count("GET jobs?batchId=b1") != 1 {
+		t.Errorf("Query not sent to the server %v", recorder.calls)
+	}
+}
+
+func TestQueryJobsClientSideByDefault(t *testing.T) {
+	recorder := &mockRecorder{}
+	routes := map[string]mockHandler{
+		"GET alive": xmlRoute(aliveXml, 200),
+		"GET jobs":  xmlRoute(jobsXml, 200),
+	}
+	pipeline := createPipeline(routedClientMock(routes, recorder))
+	if _, err := pipeline.QueryJobs(JobQuery{BatchId: "b1"}); err != nil {
+		t.Errorf("Unexpected error %v", err)
+	}
+	if recorder.count("GET jobs?") != 0 || recorder.count("GET alive") != 0 {
+		t.Errorf("Query sent to the server %v", recorder.calls)
+	}
+	withQueries, err := NewPipeline("http://localhost:8181/ws/", WithJobQueries(Version{Major: 1, Minor: 6}))
+	if err != nil {
+		t.Fatalf("Unexpected error %v", err)
+	}
+	withQueries.BaseUrl = "base/"
+	withQueries.clientMaker = routedClientMock(routes, recorder)
+	withQueries.QueryJobs(JobQuery{BatchId: "b1"})
+	if recorder.count("GET jobs?batchId=b1") != 1 {
+		t.Errorf("Query not sent to the server %v", recorder.calls)
+	}
+}
@@ -0,0 +1,141 @@
+package pipeline
+
+import (
+	"encoding/xml"
+	"io"
+	"net/url"
+	"regexp"
+	"strings"
+	"time"
+)
+
+//Structured logger, args are alternating keys and values. *slog.Logger
+//satisfies it
+type Logger interface {
+	Debug(msg string, args ...interface{})
+	Info(msg string, args ...interface{})
+	Error(msg string, args ...interface{})
+}
+
+//Logger discarding everything, used by default
+type nopLogger struct{}
+
+func (nopLogger) Debug(string, ...interface{}) {}
+func (nopLogger) Info(string, ...interface{})  {}
+func (nopLogger) Error(string, ...interface{}) {}
+
+//Placeholder for redacted values
+const REDACTED = "[REDACTED]"
+
+var secretAttrRegexp = regexp.MustCompile(`(secret\s*=\s*)("[^"]*"|'[^']*')`)
+
+//Sets the logger, nil silences the pipeline
+func (p *Pipeline) SetLogger(logger Logger) {
+	p.logger = logger
+}
+
+//Dumps the request and response bodies at debug level, secrets are
+//redacted
+func (p *Pipeline) SetLogBodies(on bool) {
+	p.logBodies = on
+}
+
+func (p Pipeline) log() Logger {
+	if p.logger == nil {
+		return nopLogger{}
+	}
+	return p.logger
+}
+
+//Removes the signature from the url
+func redactUrl(rawUrl string) string {
+	u, err := url.Parse(rawUrl)
+	if err != nil {
+		return rawUrl
+	}
+	query := u.Query()
+	if _, ok := query["sign"]; !ok {
+		return rawUrl
+	}
+	query.Del("sign")
+	u.RawQuery = query.Encode()
+	return u.String()
+}
+
+//Url of the request for the logs. The path comes from the entry template
+//with the secret arguments redacted, the query from the request, which may
+//have been extended, without the signature
+func loggedUrl(req *Request) string {
+	rawUrl := redactUrl(req.Url)
+	if req.redacted == "" {
+		return rawUrl
+	}
+	path := req.redacted
+	if idx := strings.Index(path, "?"); idx >= 0 {
+		path = path[:idx]
+	}
+	if idx := strings.Index(rawUrl, "?"); idx >= 0 {
+		return path + rawUrl[idx:]
+	}
+	return path
+}
+
+//Replaces the values of the secret attributes
+func redactBody(body string) string {
+	return secretAttrRegexp.ReplaceAllString(body, `${1}"`+REDACTED+`"`)
+}
+
+//Xml representation of a request or response body, binary data is not
+//dumped
+func dumpBody(v interface{}) string {
+	switch v.(type) {
+	case nil:
+		return ""
+	case *MultipartData, *RawData, io.Writer:
+		return "(binary data)"
+	}
+	data, err := xml.Marshal(v)
+	if err != nil {
+		return "(" + err.Error() + ")"
+	}
+	return redactBody(string(data))
+}
+
+//Bytes sent and received by the request, zero if unknown
+func transferred(req *Request) (sent, received int64) {
+	if resp := req.HttpResponse; resp != nil {
+		received = resp.ContentLength
+		if resp.Request != nil {
+			sent = resp.Request.ContentLength
+		}
+	}
+	return
+}
+
+//Logs the outcome of a request
+func (p Pipeline) logRequest(req *Request, status int, elapsed time.Duration, err error) {
+	logger := p.log()
+	sent, received := transferred(req)
+	args := []interface{}{
+		"entry", req.Entry,
+		"method", req.Method,
+		"url", loggedUrl(req),
+		"status", status,
+		"duration", elapsed,
+		"sent", sent,
+		"received", received,
+	}
+	if err != nil {
+		logger.Error("Request failed", append(args, "error", err)...)
+	} else {
+		logger.Debug("Request", args...)
+	}
+	if p.logBodies {
+		var response interface{} = req.Error
+		if err == nil {
+			response = req.Result
+		}
+		logger.Debug("Request bodies", "entry", req.Entry,
+			"request", dumpBody(req.Data), "response", dumpBody(response))
+	}
+}
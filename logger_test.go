@@ -0,0 +1,139 @@
+package pipeline
+
+import (
+	"fmt"
+	"strings"
+	"sync"
+	"testing"
+)
+
+//Logger keeping the entries as "LEVEL msg key=value..."
+type recordingLogger struct {
+	mu      sync.Mutex
+	entries []string
+}
+
+func (l *recordingLogger) add(level, msg string, args []interface{}) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	entry := level + " " + msg
+	for i := 0; i+1 < len(args); i += 2 {
+		entry += fmt.Sprintf(" %v=%v", args[i], args[i+1])
+	}
+	l.entries = append(l.entries, entry)
+}
+
+func (l *recordingLogger) Debug(msg string, args ...interface{}) { l.add("DEBUG", msg, args) }
+func (l *recordingLogger) Info(msg string, args ...interface{})  { l.add("INFO", msg, args) }
+func (l *recordingLogger) Error(msg string, args ...interface{}) { l.add("ERROR", msg, args) }
+
+func (l *recordingLogger) all() string {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	return strings.Join(l.entries, "\n")
+}
+
+func TestRedactUrl(t *testing.T) {
+	tests := map[string]string{
+		"http://host/ws/jobs?authid=cli&time=t&sign=abc%3D": "http://host/ws/jobs?authid=cli&time=t",
+		"http://host/ws/jobs?sign=abc&msgSeq=2":             "http://host/ws/jobs?msgSeq=2",
+		"http://host/ws/alive":                              "http://host/ws/alive",
+	}
+	for in, exp := range tests {
+		if res := redactUrl(in); res != exp {
+			t.Errorf(T_STRING, in, exp, res)
+		}
+	}
+}
+
+func TestDumpBodyRedactsSecrets(t *testing.T) {
+	res := dumpBody(&Client{Id: "cli", Secret: "shhh"})
+	if strings.Contains(res, "shhh") || !strings.Contains(res, `secret="`+REDACTED+`"`) {
+		t.Errorf("Secret not redacted %v", res)
+	}
+	if res := dumpBody(&RawData{}); res != "(binary data)" {
+		t.Errorf(T_STRING, "raw", "(binary data)", res)
+	}
+	if res := dumpBody(nil); res != "" {
+		t.Errorf(T_STRING, "nil", "", res)
+	}
+}
+
+func TestLoggerFields(t *testing.T) {
+	logger := &recordingLogger{}
+	pipeline := createPipeline(xmlClientMock(aliveXml, 200))
+	pipeline.SetCredentials("cli", "shhh")
+	pipeline.SetLogger(logger)
+	if _, err := pipeline.Alive(); err != nil {
+		t.Fatalf("Unexpected error %v", err)
+	}
+	res := logger.all()
+	for _, exp := range []string{"DEBUG Request", "entry=alive", "method=GET", "status=200", "authid=cli"} {
+		if !strings.Contains(res, exp) {
+			t.Errorf("%v not logged in %v", exp, res)
+		}
+	}
+	if strings.Contains(res, "sign=") {
+		t.Errorf("Signature logged %v", res)
+	}
+	if strings.Contains(res, "Request bodies") {
+		t.Errorf("Bodies logged by default %v", res)
+	}
+}
+
+func TestLoggerErrors(t *testing.T) {
+	logger := &recordingLogger{}
+	pipeline := createPipeline(failingMock())
+	pipeline.SetLogger(logger)
+	pipeline.Alive()
+	if res := logger.all(); !strings.Contains(res, "ERROR Request failed entry=alive") || !strings.Contains(res, "error=WS ERROR") {
+		t.Errorf("Failure not logged %v", res)
+	}
+}
+
+func TestLoggerRedactsSecretArgs(t *testing.T) {
+	logger := &recordingLogger{}
+	pipeline := createPipeline(routedClientMock(map[string]mockHandler{
+		"GET admin/halt/": xmlRoute("", 500),
+	}, &mockRecorder{}))
+	pipeline.SetCredentials("cli", "shhh")
+	pipeline.SetLogger(logger)
+	if err := pipeline.Halt("SUPERSECRETKEY"); err == nil {
+		t.Fatalf("Expected error")
+	}
+	res := logger.all()
+	if strings.Contains(res, "SUPERSECRETKEY") || strings.Contains(res, "sign=") {
+		t.Errorf("Secret logged %v", res)
+	}
+	if !strings.Contains(res, "url=base/admin/halt/"+REDACTED+"?authid=cli") {
+		t.Errorf("Redacted url not logged %v", res)
+	}
+}
+
+func TestLoggerBodies(t *testing.T) {
+	logger := &recordingLogger{}
+	pipeline := createPipeline(xmlClientMock(`<client xmlns="http://www.daisy.org/ns/pipeline/data" id="cli" secret="fromserver" role="CLIENTAPP"/>`, 201))
+	pipeline.SetLogger(logger)
+	pipeline.SetLogBodies(true)
+	if _, err := pipeline.NewClient(Client{Id: "cli", Role: ROLE_CLIENTAPP, Secret: "supersecret"}); err != nil {
+		t.Fatalf("Unexpected error %v", err)
+	}
+	res := logger.all()
+	if !strings.Contains(res, "Request bodies entry=new_client") {
+		t.Errorf("Bodies not logged %v", res)
+	}
+	if strings.Contains(res, "supersecret") || strings.Contains(res, "fromserver") {
+		t.Errorf("Secrets logged %v", res)
+	}
+}
+
+func TestSilentByDefault(t *testing.T) {
+	pipeline := createPipeline(xmlClientMock(aliveXml, 200))
+	if _, ok := pipeline.log().(nopLogger); !ok {
+		t.Errorf("Default logger is not silent")
+	}
+	pipeline.SetLogger(nil)
+	if _, ok := pipeline.log().(nopLogger); !ok {
+		t.Errorf("Nil logger is not silent")
+	}
+}
@@ -0,0 +1,280 @@
+package pipeline
+
+import (
+	"bufio"
+	"bytes"
+	"fmt"
+	"io"
+	"regexp"
+	"strings"
+	"time"
+)
+
+//Log levels as written by the framework, ordered by severity
+type LogLevel int
+
+const (
+	LEVEL_UNKNOWN LogLevel = iota
+	LEVEL_TRACE
+	LEVEL_DEBUG
+	LEVEL_INFO
+	LEVEL_WARN
+	LEVEL_ERROR
+)
+
+var logLevelNames = map[LogLevel]string{
+	LEVEL_UNKNOWN: "UNKNOWN",
+	LEVEL_TRACE:   "TRACE",
+	LEVEL_DEBUG:   "DEBUG",
+	LEVEL_INFO:    "INFO",
+	LEVEL_WARN:    "WARN",
+	LEVEL_ERROR:   "ERROR",
+}
+
+//Returns the name of the level as written in the log
+func (l LogLevel) String() string {
+	if name, ok := logLevelNames[l]; ok {
+		return name
+	}
+	return fmt.Sprintf("LogLevel(%d)", int(l))
+}
+
+//Parses a level name, WARNING is accepted as an alias for WARN
+func ParseLogLevel(name string) LogLevel {
+	switch strings.ToUpper(strings.TrimSpace(name)) {
+	case "TRACE":
+		return LEVEL_TRACE
+	case "DEBUG":
+		return LEVEL_DEBUG
+	case "INFO":
+		return LEVEL_INFO
+	case "WARN", "WARNING":
+		return LEVEL_WARN
+	case "ERROR":
+		return LEVEL_ERROR
+	}
+	return LEVEL_UNKNOWN
+}
+
+//A single entry of a job log
+type LogRecord struct {
+	Time    time.Time //Timestamp of the entry, the log carries no zone so it's returned as UTC
+	Level   LogLevel  //Level of the entry
+	Thread  string    //Thread name, empty if the log pattern doesn't include it
+	Logger  string    //Logger (component) that emitted the entry
+	Message string    //First line of the message
+	Trace   []string  //Lines following the entry which don't start a new one, usually a stack trace
+	Line    int       //Line number in the log where the entry starts
+}
+
+//Returns the message and the trace as a single block of text
+func (r LogRecord) FullMessage() string {
+	if len(r.Trace) == 0 {
+		return r.Message
+	}
+	return r.Message + "\n" + strings.Join(r.Trace, "\n")
+}
+
+//Matches lines like:
+//
+//  2013-05-07 15:51:42,123 [INFO ] org.daisy.pipeline.Foo - message
+//  2013-05-07 15:51:42,123 [main] INFO  org.daisy.pipeline.Foo - message
+//  2013-05-07 15:51:42,123 [DEBUG] [main] org.daisy.pipeline.Foo - message
+var logLineRegexp = regexp.MustCompile(
+	`^(\d{4}-\d{2}-\d{2}[ T]\d{2}:\d{2}:\d{2}(?:[,.]\d{1,9})?)\s+` + //timestamp
+		`(?:\[([^\]]*)\]\s+)??` + //thread before the level
+		`\[?\s*(TRACE|DEBUG|INFO|WARN|WARNING|ERROR)\s*\]?\s+` + //level
+		`(?:\[([^\]]*)\]\s+)?` + //thread after the level
+		`(\S+)\s+-\s?(.*)$`) //logger and message
+
+var logTimeLayouts = []string{
+	"2006-01-02 15:04:05.999999999",
+	"2006-01-02T15:04:05.999999999",
+}
+
+func parseLogTime(value string) (t time.Time, err error) {
+	value = strings.Replace(value, ",", ".", 1)
+	for _, layout := range logTimeLayouts {
+		if t, err = time.Parse(layout, value); err == nil {
+			return
+		}
+	}
+	return
+}
+
+//Parses a single line, ok is false if the line doesn't start a new entry
+func parseLogLine(line string) (record LogRecord, ok bool) {
+	m := logLineRegexp.FindStringSubmatch(line)
+	if m == nil {
+		return
+	}
+	t, err := parseLogTime(m[1])
+	if err != nil {
+		return
+	}
+	thread := m[2]
+	if thread == "" {
+		thread = m[4]
+	}
+	return LogRecord{
+		Time:    t,
+		Level:   ParseLogLevel(m[3]),
+		Thread:  thread,
+		Logger:  m[5],
+		Message: m[6],
+	}, true
+}
+
+//Filters log records, only those for which the filter returns true are kept
+type LogFilter func(LogRecord) bool
+
+//Keeps records at the given level or above
+func MinLevel(level LogLevel) LogFilter {
+	return func(r LogRecord) bool {
+		return r.Level >= level
+	}
+}
+
+//Keeps records whose logger starts with any of the given prefixes
+func LoggerPrefix(prefixes ...string) LogFilter {
+	return func(r LogRecord) bool {
+		for _, prefix := range prefixes {
+			if strings.HasPrefix(r.Logger, prefix) {
+				return true
+			}
+		}
+		return false
+	}
+}
+
+//Keeps records whose message or trace contains the given text
+func MessageContains(text string) LogFilter {
+	return func(r LogRecord) bool {
+		return strings.Contains(r.FullMessage(), text)
+	}
+}
+
+//Keeps records within [from, to), zero values leave that side open
+func Between(from, to time.Time) LogFilter {
+	return func(r LogRecord) bool {
+		if !from.IsZero() && r.Time.Before(from) {
+			return false
+		}
+		if !to.IsZero() && !r.Time.Before(to) {
+			return false
+		}
+		return true
+	}
+}
+
+//Iterates over the records of a log in the fashion of bufio.Scanner
+//
+//  scanner := NewLogScanner(r, MinLevel(LEVEL_ERROR))
+//  for scanner.Scan() {
+//          record := scanner.Record()
+//  }
+//  if err := scanner.Err(); err != nil {
+//  }
+type LogScanner struct {
+	lines   *bufio.Scanner
+	filters []LogFilter
+	current LogRecord
+	next    *LogRecord
+	lineNo  int
+	done    bool
+}
+
+//Maximum length of a single log line
+const MAX_LOG_LINE = 1024 * 1024
+
+//Creates a scanner reading the log from r, records not passing every filter are skipped
+func NewLogScanner(r io.Reader, filters ...LogFilter) *LogScanner {
+	lines := bufio.NewScanner(r)
+	lines.Buffer(make([]byte, 0, 64*1024), MAX_LOG_LINE)
+	return &LogScanner{lines: lines, filters: filters}
+}
+
+//Advances to the next record, returns false when the log is exhausted or an error occurred
+func (s *LogScanner) Scan() bool {
+	for {
+		record, ok := s.read()
+		if !ok {
+			return false
+		}
+		if s.accept(record) {
+			s.current = record
+			return true
+		}
+	}
+}
+
+//Returns the record read by the last call to Scan
+func (s *LogScanner) Record() LogRecord {
+	return s.current
+}
+
+//Returns the first error found while reading the log
+func (s *LogScanner) Err() error {
+	return s.lines.Err()
+}
+
+func (s *LogScanner) accept(record LogRecord) bool {
+	for _, filter := range s.filters {
+		if !filter(record) {
+			return false
+		}
+	}
+	return true
+}
+
+//Reads a whole record, continuation lines are appended to its trace
+func (s *LogScanner) read() (record LogRecord, ok bool) {
+	if s.done {
+		return
+	}
+	for s.lines.Scan() {
+		s.lineNo++
+		line := strings.TrimRight(s.lines.Text(), "\r")
+		parsed, isRecord := parseLogLine(line)
+		if isRecord {
+			parsed.Line = s.lineNo
+			if s.next != nil {
+				record, ok = *s.next, true
+				s.next = &parsed
+				return
+			}
+			s.next = &parsed
+			continue
+		}
+		if s.next == nil {
+			//garbage before the first entry, keep it as an unknown record
+			s.next = &LogRecord{Message: line, Line: s.lineNo}
+			continue
+		}
+		s.next.Trace = append(s.next.Trace, line)
+	}
+	s.done = true
+	if s.next != nil {
+		record, ok = *s.next, true
+		s.next = nil
+	}
+	return
+}
+
+//Parses the whole log returning the records passing every filter
+func ParseLog(data []byte, filters ...LogFilter) (records []LogRecord, err error) {
+	scanner := NewLogScanner(bytes.NewReader(data), filters...)
+	for scanner.Scan() {
+		records = append(records, scanner.Record())
+	}
+	return records, scanner.Err()
+}
+
+//Gets the log file for a job parsed into records
+func (p Pipeline) LogRecords(id string, filters ...LogFilter) (records []LogRecord, err error) {
+	data, err := p.Log(id)
+	if err != nil {
+		return nil, err
+	}
+	return ParseLog(data, filters...)
+}
@@ -0,0 +1,102 @@
+package pipeline
+
+import (
+	"bytes"
+	"testing"
+	"time"
+)
+
+const jobLog = `2013-05-07 15:51:42,123 [INFO ] org.daisy.pipeline.job.Job - Starting job
+2013-05-07 15:51:42,200 [DEBUG] [worker-1] com.xmlcalabash.runtime.XPipeline - Running step
+2013-05-07 15:51:43,001 [ERROR] org.daisy.common.xproc.calabash.CalabashXProcPipeline - XProc error
+org.daisy.common.xproc.XProcErrorException: err:XD0011
+	at com.xmlcalabash.library.Load.run(Load.java:120)
+	... 12 more
+2013-05-07 15:51:44,000 [main] WARN  org.daisy.pipeline.job.Job - Job finished with errors
+`
+
+func TestParseLog(t *testing.T) {
+	records, err := ParseLog([]byte(jobLog))
+	if err != nil {
+		t.Errorf("Unexpected error %v", err)
+	}
+	if len(records) != 4 {
+		t.Fatalf(T_STRING, "records len", 4, len(records))
+	}
+	first := records[0]
+	if exp := time.Date(2013, 5, 7, 15, 51, 42, 123000000, time.UTC); !first.Time.Equal(exp) {
+		t.Errorf(T_STRING, "time", exp, first.Time)
+	}
+	if first.Level != LEVEL_INFO {
+		t.Errorf(T_STRING, "level", LEVEL_INFO, first.Level)
+	}
+	if first.Logger != "org.daisy.pipeline.job.Job" {
+		t.Errorf(T_STRING, "logger", "org.daisy.pipeline.job.Job", first.Logger)
+	}
+	if first.Message != "Starting job" {
+		t.Errorf(T_STRING, "message", "Starting job", first.Message)
+	}
+	if records[1].Thread != "worker-1" {
+		t.Errorf(T_STRING, "thread", "worker-1", records[1].Thread)
+	}
+	if len(records[2].Trace) != 3 {
+		t.Errorf(T_STRING, "trace len", 3, len(records[2].Trace))
+	}
+	if records[2].Line != 3 {
+		t.Errorf(T_STRING, "line", 3, records[2].Line)
+	}
+	if records[3].Thread != "main" || records[3].Level != LEVEL_WARN {
+		t.Errorf(T_STRING, "thread and level", "main WARN", records[3].Thread+" "+records[3].Level.String())
+	}
+}
+
+func TestParseLogFilters(t *testing.T) {
+	records, err := ParseLog([]byte(jobLog), MinLevel(LEVEL_WARN))
+	if err != nil {
+		t.Errorf("Unexpected error %v", err)
+	}
+	if len(records) != 2 {
+		t.Errorf(T_STRING, "records len", 2, len(records))
+	}
+	records, _ = ParseLog([]byte(jobLog), LoggerPrefix("com.xmlcalabash"))
+	if len(records) != 1 || records[0].Message != "Running step" {
+		t.Errorf("Wrong records by logger %+v", records)
+	}
+	records, _ = ParseLog([]byte(jobLog), MessageContains("XD0011"))
+	if len(records) != 1 || records[0].Level != LEVEL_ERROR {
+		t.Errorf("Wrong records by message %+v", records)
+	}
+	from := time.Date(2013, 5, 7, 15, 51, 43, 0, time.UTC)
+	records, _ = ParseLog([]byte(jobLog), Between(from, time.Time{}))
+	if len(records) != 2 {
+		t.Errorf(T_STRING, "records len", 2, len(records))
+	}
+}
+
+func TestLogScannerLeadingGarbage(t *testing.T) {
+	scanner := NewLogScanner(bytes.NewBufferString("garbage\n" + jobLog))
+	if !scanner.Scan() {
+		t.Fatal("Expected a record")
+	}
+	if rec := scanner.Record(); rec.Level != LEVEL_UNKNOWN || rec.Message != "garbage" {
+		t.Errorf("Wrong leading record %+v", rec)
+	}
+	count := 1
+	for scanner.Scan() {
+		count++
+	}
+	if count != 5 {
+		t.Errorf(T_STRING, "records", 5, count)
+	}
+}
+
+func TestLogRecords(t *testing.T) {
+	pipeline := createPipeline(xmlClientMock(jobLog, 200))
+	records, err := pipeline.LogRecords("id1", MinLevel(LEVEL_ERROR))
+	if err != nil {
+		t.Errorf("Unexpected error %v", err)
+	}
+	if len(records) != 1 {
+		t.Errorf(T_STRING, "records len", 1, len(records))
+	}
+}
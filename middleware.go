@@ -0,0 +1,61 @@
+package pipeline
+
+import (
+	"net/http"
+)
+
+//Performs a request returning the http status
+type Handler func(req *Request) (status int, err error)
+
+//Wraps a handler to act before and after the request is sent. A middleware
+//may change the request, e.g. adding headers or rewriting the url, or answer
+//it without calling next by filling req.Result, e.g. when caching
+type Middleware func(next Handler) Handler
+
+//Adds middlewares to the chain, the ones added first see the request first
+func (p *Pipeline) Use(middlewares ...Middleware) {
+	//copy so pipelines copied before don't share the new middlewares
+	chain := make([]Middleware, 0, len(p.middlewares)+len(middlewares))
+	p.middlewares = append(append(chain, p.middlewares...), middlewares...)
+}
+
+//Replaces the authentication middleware, which runs after the ones added
+//with Use so it sees the final request. nil sends requests unsigned
+func (p *Pipeline) SetAuthenticator(auth Middleware) {
+	p.authenticator = auth
+}
+
+//Sets the header in every request
+func SetHeader(name, value string) Middleware {
+	return func(next Handler) Handler {
+		return func(req *Request) (int, error) {
+			if req.Header == nil {
+				req.Header = &http.Header{}
+			}
+			req.Header.Set(name, value)
+			return next(req)
+		}
+	}
+}
+
+//Sends the request to the server
+func (p Pipeline) send(req *Request) (int, error) {
+	cli := p.clientMaker()
+	if hc, ok := cli.(*httpClient); ok {
+		hc.ctx = req.Context
+	}
+	return cli.Do(req.RequestResponse)
+}
+
+//Handler going through the middlewares, the authenticator and finally
+//sending the request
+func (p Pipeline) chain() Handler {
+	handler := Handler(p.send)
+	if p.signing() {
+		handler = p.authenticator(handler)
+	}
+	for i := len(p.middlewares) - 1; i >= 0; i-- {
+		handler = p.middlewares[i](handler)
+	}
+	return handler
+}
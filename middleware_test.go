@@ -0,0 +1,107 @@
+package pipeline
+
+import (
+	"errors"
+	"strings"
+	"testing"
+)
+
+//Middleware appending its name to calls before and after the request
+func tracingMiddleware(name string, calls *[]string) Middleware {
+	return func(next Handler) Handler {
+		return func(req *Request) (int, error) {
+			*calls = append(*calls, name+" "+req.Entry)
+			status, err := next(req)
+			*calls = append(*calls, name+" done")
+			return status, err
+		}
+	}
+}
+
+func TestMiddlewareOrder(t *testing.T) {
+	calls := []string{}
+	pipeline := createPipeline(xmlClientMock(aliveXml, 200))
+	pipeline.Use(tracingMiddleware("first", &calls), tracingMiddleware("second", &calls))
+	if _, err := pipeline.Alive(); err != nil {
+		t.Fatalf("Unexpected error %v", err)
+	}
+	exp := "first alive,second alive,second done,first done"
+	if res := strings.Join(calls, ","); res != exp {
+		t.Errorf(T_STRING, "order", exp, res)
+	}
+}
+
+func TestMiddlewareShortCircuit(t *testing.T) {
+	cli := &MockClient{}
+	pipeline := createPipeline(func() doer { return cli })
+	pipeline.Use(func(next Handler) Handler {
+		return func(req *Request) (int, error) {
+			req.Result.(*Alive).Version = "cached"
+			return 200, nil
+		}
+	})
+	alive, err := pipeline.Alive()
+	if err != nil {
+		t.Fatalf("Unexpected error %v", err)
+	}
+	if alive.Version != "cached" || cli.request.Url != "" {
+		t.Errorf("Request not answered by the middleware %v %v", alive.Version, cli.request.Url)
+	}
+}
+
+func TestMiddlewareFault(t *testing.T) {
+	fault := errors.New("injected")
+	pipeline := createPipeline(xmlClientMock(aliveXml, 200))
+	pipeline.Use(func(next Handler) Handler {
+		return func(req *Request) (int, error) {
+			return 0, fault
+		}
+	})
+	if _, err := pipeline.Alive(); err != fault {
+		t.Errorf(T_STRING, "error", fault, err)
+	}
+}
+
+func TestAuthenticatorIsInnermost(t *testing.T) {
+	cli := &MockClient{status: 200}
+	pipeline := createPipeline(func() doer { return cli })
+	pipeline.SetCredentials("cli", "shhh")
+	pipeline.Use(func(next Handler) Handler {
+		return func(req *Request) (int, error) {
+			req.Url = strings.Replace(req.Url, "base/", "http://example.org/ws/", 1)
+			return next(req)
+		}
+	})
+	pipeline.Halt("key")
+	url := cli.request.Url
+	if !strings.HasPrefix(url, "http://example.org/ws/admin/halt/key?authid=cli") || !strings.Contains(url, "sign=") {
+		t.Errorf("Rewritten url not signed %v", url)
+	}
+	pipeline.SetAuthenticator(nil)
+	pipeline.Halt("key")
+	if strings.Contains(cli.request.Url, "sign=") {
+		t.Errorf("Url signed without authenticator %v", cli.request.Url)
+	}
+}
+
+func TestSetHeader(t *testing.T) {
+	cli := &MockClient{status: 204}
+	pipeline := createPipeline(func() doer { return cli })
+	pipeline.Use(SetHeader("X-Trace", "abc"))
+	pipeline.Halt("key")
+	if cli.request.Header == nil || cli.request.Header.Get("X-Trace") != "abc" {
+		t.Errorf("Header not set %v", cli.request.Header)
+	}
+}
+
+func TestUseDoesNotAffectCopies(t *testing.T) {
+	calls := []string{}
+	pipeline := createPipeline(xmlClientMock(aliveXml, 200))
+	pipeline.Use(tracingMiddleware("first", &calls))
+	other := pipeline
+	other.Use(tracingMiddleware("second", &calls))
+	pipeline.Alive()
+	if res := strings.Join(calls, ","); res != "first alive,first done" {
+		t.Errorf(T_STRING, "calls", "first alive,first done", res)
+	}
+}
@@ -5,6 +5,8 @@ import (
 	"encoding/xml"
 	"io"
 	"reflect"
+	"strings"
+	"sync"
 
 	"github.com/capitancambio/restclient"
 )
@@ -140,5 +142,83 @@ func emptyClientMock() doer {
 
 //Creates a pipeline using the given mocked doer
 func createPipeline(maker func() doer) Pipeline {
-	return Pipeline{BaseUrl: "base/", clientMaker: maker, authenticator: func(*restclient.RequestResponse) {}}
+	return Pipeline{BaseUrl: "base/", clientMaker: maker}
+}
+
+//Computes the response and status of a routed mock from the request
+type mockHandler func(rr *restclient.RequestResponse) (response string, status int)
+
+//Handler always returning the same xml and status
+func xmlRoute(response string, status int) mockHandler {
+	return func(*restclient.RequestResponse) (string, int) {
+		return response, status
+	}
+}
+
+//Keeps track of the requests done through a routed mock
+type mockRecorder struct {
+	mutex sync.Mutex
+	calls []string
+}
+
+func (r *mockRecorder) record(call string) {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+	r.calls = append(r.calls, call)
+}
+
+//Returns the number of calls starting with prefix
+func (r *mockRecorder) count(prefix string) (n int) {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+	for _, call := range r.calls {
+		if strings.HasPrefix(call, prefix) {
+			n++
+		}
+	}
+	return
+}
+
+//Mock answering depending on the method and the url of the request
+type RoutedMockClient struct {
+	MockClient
+	routes   map[string]mockHandler
+	recorder *mockRecorder
+}
+
+//Routes are matched as "METHOD path" where path is relative to the base url,
+//the longest matching prefix wins
+func (m *RoutedMockClient) Do(rr *restclient.RequestResponse) (status int, err error) {
+	call := rr.Method + " " + strings.TrimPrefix(rr.Url, "base/")
+	m.recorder.record(call)
+	best := ""
+	for prefix := range m.routes {
+		if strings.HasPrefix(call, prefix) && len(prefix) > len(best) {
+			best = prefix
+		}
+	}
+	if handler, ok := m.routes[best]; ok && best != "" {
+		m.response, m.status = handler(rr)
+	} else {
+		m.response, m.status = "", 404
+	}
+	return m.MockClient.Do(rr)
+}
+
+//xml based mock client routing the requests to the given handlers
+func routedClientMock(routes map[string]mockHandler, recorder *mockRecorder) func() doer {
+	return func() doer {
+		return &RoutedMockClient{
+			MockClient: MockClient{
+				EncoderSupplier: func(w io.Writer) restclient.Encoder {
+					return xml.NewEncoder(w)
+				},
+				DecoderSupplier: func(r io.Reader) restclient.Decoder {
+					return xml.NewDecoder(r)
+				},
+			},
+			routes:   routes,
+			recorder: recorder,
+		}
+	}
 }
@@ -0,0 +1,153 @@
+package pipeline
+
+import (
+	"fmt"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+)
+
+//Settings gathered from the options
+type config struct {
+	httpClient *http.Client
+	timeout    time.Duration
+	userAgent  string
+	retry      *RetryPolicy
+	logger     Logger
+	auth       Middleware
+	mutating   Version
+	jobQuery   Version
+	history    *DurationHistory
+}
+
+//Configures the pipeline created by NewPipeline
+type PipelineOption func(*config)
+
+//Time limit for each request, including reading the response
+func WithTimeout(timeout time.Duration) PipelineOption {
+	return func(c *config) { c.timeout = timeout }
+}
+
+//Http client used for the requests, e.g. with custom TLS settings. The
+//timeout option applies to a copy of it
+func WithHttpClient(client *http.Client) PipelineOption {
+	return func(c *config) { c.httpClient = client }
+}
+
+//Logger for the requests, see SetLogger
+func WithLogger(logger Logger) PipelineOption {
+	return func(c *config) { c.logger = logger }
+}
+
+//Signs the requests with the client key and secret
+func WithCredentials(clientKey, clientSecret string) PipelineOption {
+	return func(c *config) { c.auth = Authenticate(clientKey, clientSecret) }
+}
+
+//Signs the requests with the credentials from the provider
+func WithCredentialProvider(provider CredentialProvider) PipelineOption {
+	return func(c *config) { c.auth = Signer{Provider: provider}.Middleware() }
+}
+
+//User-Agent header sent with the requests
+func WithUserAgent(userAgent string) PipelineOption {
+	return func(c *config) { c.userAgent = userAgent }
+}
+
+//Retries the failed idempotent requests
+func WithRetry(policy RetryPolicy) PipelineOption {
+	return func(c *config) { c.retry = &policy }
+}
+
+//Sends POST to move jobs and halt, see SetMutatingMethodVersion
+func WithMutatingMethods(since Version) PipelineOption {
+	return func(c *config) { c.mutating = since }
+}
+
+//Sends the job queries to the server, see SetJobQueryVersion
+func WithJobQueries(since Version) PipelineOption {
+	return func(c *config) { c.jobQuery = since }
+}
+
+//History filled with the durations of the jobs watched or run in batches,
+//see SetDurationHistory
+func WithDurationHistory(history *DurationHistory) PipelineOption {
+	return func(c *config) { c.history = history }
+}
+
+//Creates a pipeline for the framework at baseUrl, e.g.
+//http://localhost:8181/ws. The url must be absolute, a trailing slash is
+//added if missing
+func NewPipeline(baseUrl string, opts ...PipelineOption) (*Pipeline, error) {
+	base, err := normalizeBaseUrl(baseUrl)
+	if err != nil {
+		return nil, err
+	}
+	cfg := &config{}
+	for _, opt := range opts {
+		opt(cfg)
+	}
+	if cfg.history == nil {
+		cfg.history = NewDurationHistory(DEFAULT_HISTORY_SIZE)
+	}
+	client := cfg.httpClient
+	if client == nil {
+		client = &http.Client{}
+	}
+	if cfg.timeout > 0 {
+		withTimeout := *client
+		withTimeout.Timeout = cfg.timeout
+		client = &withTimeout
+	}
+	p := &Pipeline{
+		BaseUrl:       base,
+		clientMaker:   func() doer { return newHttpClient(client) },
+		authenticator: cfg.auth,
+		logger:        cfg.logger,
+		drain:         &drainFlag{},
+		caps:          newCapabilities(),
+		mutatingSince: cfg.mutating,
+		jobQuerySince: cfg.jobQuery,
+		history:       cfg.history,
+	}
+	if cfg.userAgent != "" {
+		p.Use(SetHeader("User-Agent", cfg.userAgent))
+	}
+	if cfg.retry != nil {
+		p.Use(cfg.retry.Middleware())
+	}
+	return p, nil
+}
+
+//Checks that the url is absolute and makes sure it ends with a slash so
+//the api paths can be appended
+func normalizeBaseUrl(baseUrl string) (string, error) {
+	u, err := url.Parse(strings.TrimSpace(baseUrl))
+	if err != nil {
+		return "", fmt.Errorf("Invalid base url %q: %v", baseUrl, err)
+	}
+	if (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+		return "", fmt.Errorf("Invalid base url %q, expected http(s)://host[:port]/path", baseUrl)
+	}
+	if u.RawQuery != "" || u.Fragment != "" || u.User != nil {
+		return "", fmt.Errorf("Invalid base url %q, query, fragment and user info are not allowed", baseUrl)
+	}
+	if !strings.HasSuffix(u.Path, "/") {
+		u.Path += "/"
+		if u.RawPath != "" {
+			u.RawPath += "/"
+		}
+	}
+	return u.String(), nil
+}
+
+//Sets the base url of the framework, it's validated as in NewPipeline
+func (p *Pipeline) SetUrl(baseUrl string) error {
+	base, err := normalizeBaseUrl(baseUrl)
+	if err != nil {
+		return err
+	}
+	p.BaseUrl = base
+	return nil
+}
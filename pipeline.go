@@ -1,9 +1,9 @@
 package pipeline
 
 import (
+	"context"
 	"fmt"
 	"io"
-	"log"
 	"math"
 	"encoding/xml"
 	"strings"
@@ -42,61 +42,100 @@ const (
 
 //Defines the information for an api entry
 type apiEntry struct {
-	urlPath  string
-	method   string
-	okStatus int
+	urlPath    string //template, see expandUrl
+	method     string
+	okStatus   int
+	idempotent bool //the request can be repeated without further effects
 }
 
 //Available api entries
 var apiEntries = map[string]apiEntry{
-	API_ALIVE:                 apiEntry{"alive", "GET", 200},
-	API_SCRIPTS:               apiEntry{"scripts", "GET", 200},
-	API_SCRIPT:                apiEntry{"scripts/%v", "GET", 200},
-	API_DATATYPE:              apiEntry{"datatypes/%v", "GET", 200},
-	API_STYLESHEET_PARAMETERS: apiEntry{"stylesheet-parameters", "POST", 200},
-	API_JOBREQUEST:            apiEntry{"jobs", "POST", 201},
-	API_JOB:                   apiEntry{"jobs/%v?msgSeq=%v", "GET", 200},
-	API_DEL_JOB:               apiEntry{"jobs/%v", "DELETE", 204},
-	API_RESULT:                apiEntry{"jobs/%v/result", "GET", 200},
-	API_JOBS:                  apiEntry{"jobs", "GET", 200},
-	API_QUEUE:                 apiEntry{"queue", "GET", 200},
-	API_MOVE_UP:               apiEntry{"queue/up/%v", "GET", 200},
-	API_MOVE_DOWN:             apiEntry{"queue/down/%v", "GET", 200},
-	API_LOG:                   apiEntry{"jobs/%v/log", "GET", 200},
-	API_HALT:                  apiEntry{"admin/halt/%v", "GET", 204},
-	API_CLIENTS:               apiEntry{"admin/clients", "GET", 200},
-	API_NEWCLIENT:             apiEntry{"admin/clients", "POST", 201},
-	API_CLIENT:                apiEntry{"admin/clients/%v", "GET", 200},
-	API_DELETECLIENT:          apiEntry{"admin/clients/%v", "DELETE", 204},
-	API_MODIFYCLIENT:          apiEntry{"admin/clients/%v", "PUT", 200},
-	API_PROPERTIES:            apiEntry{"admin/properties", "GET", 200},
-	API_SIZE:                  apiEntry{"admin/sizes", "GET", 200},
-	API_BATCH:                 apiEntry{"batch/%v", "GET", 200},
-	API_DEL_BATCH:             apiEntry{"batch/%v", "DELETE", 204},
+	API_ALIVE:                 apiEntry{"alive", "GET", 200, true},
+	API_SCRIPTS:               apiEntry{"scripts", "GET", 200, true},
+	API_SCRIPT:                apiEntry{"scripts/{script}", "GET", 200, true},
+	API_DATATYPE:              apiEntry{"datatypes/{datatype}", "GET", 200, true},
+	API_STYLESHEET_PARAMETERS: apiEntry{"stylesheet-parameters", "POST", 200, true},
+	API_JOBREQUEST:            apiEntry{"jobs", "POST", 201, false},
+	API_JOB:                   apiEntry{"jobs/{job}?msgSeq={msgSeq}", "GET", 200, true},
+	API_DEL_JOB:               apiEntry{"jobs/{job}", "DELETE", 204, true},
+	API_RESULT:                apiEntry{"jobs/{job}/result", "GET", 200, true},
+	API_JOBS:                  apiEntry{"jobs", "GET", 200, true},
+	API_QUEUE:                 apiEntry{"queue", "GET", 200, true},
+	API_MOVE_UP:               apiEntry{"queue/up/{job}", "GET", 200, false},
+	API_MOVE_DOWN:             apiEntry{"queue/down/{job}", "GET", 200, false},
+	API_LOG:                   apiEntry{"jobs/{job}/log", "GET", 200, true},
+	API_HALT:                  apiEntry{"admin/halt/{key}", "GET", 204, false},
+	API_CLIENTS:               apiEntry{"admin/clients", "GET", 200, true},
+	API_NEWCLIENT:             apiEntry{"admin/clients", "POST", 201, false},
+	API_CLIENT:                apiEntry{"admin/clients/{client}", "GET", 200, true},
+	API_DELETECLIENT:          apiEntry{"admin/clients/{client}", "DELETE", 204, true},
+	API_MODIFYCLIENT:          apiEntry{"admin/clients/{client}", "PUT", 200, true},
+	API_PROPERTIES:            apiEntry{"admin/properties", "GET", 200, true},
+	API_SIZE:                  apiEntry{"admin/sizes", "GET", 200, true},
+	API_BATCH:                 apiEntry{"batch/{batch}", "GET", 200, true},
+	API_DEL_BATCH:             apiEntry{"batch/{batch}", "DELETE", 204, true},
+}
+
+//Entries that change the server state but are mapped to GET by the
+//framework. No released framework is known to accept these methods, so
+//they are only sent to servers from the version set with
+//SetMutatingMethodVersion on, the rest keep getting GET
+var mutatingMethods = map[string]string{
+	API_MOVE_UP:   "POST",
+	API_MOVE_DOWN: "POST",
+	API_HALT:      "POST",
+}
+
+//Tells whether the api entry can be safely repeated, e.g. when retrying
+func idempotent(apiEntry string) bool {
+	entry, ok := lookupEntry(apiEntry)
+	return ok && entry.idempotent
+}
+
+//Sets the proper method for the entry if the server supports it
+func (p Pipeline) negotiateMethod(req *Request) error {
+	method, ok := mutatingMethods[req.Entry]
+	if !ok || p.mutatingSince.IsZero() {
+		return nil
+	}
+	version, err := p.serverVersion()
+	if err != nil {
+		return err
+	}
+	if version.AtLeast(p.mutatingSince) {
+		req.Method = method
+	}
+	return nil
 }
 
 //Pipeline struct stores different configuration paramenters
 //for the communication with the pipeline framework
 type Pipeline struct {
-	BaseUrl       string                                //baseurl of the framework
-	clientMaker   func() doer                           //client to perform the rest queries
-	authenticator func(req *restclient.RequestResponse) //authentication function
-}
-
-func NewPipeline(baseUrl string) *Pipeline {
-	return &Pipeline{
-		BaseUrl:       baseUrl,
-		authenticator: func(*restclient.RequestResponse) {},
-		clientMaker:   newClient,
-	}
+	BaseUrl       string           //baseurl of the framework
+	clientMaker   func() doer      //client to perform the rest queries
+	authenticator Middleware       //signs the requests, innermost middleware
+	middlewares   []Middleware     //wrap every request, the first one is the outermost
+	drain         *drainFlag       //shared by the copies, set while draining
+	logger        Logger           //silent when nil
+	logBodies     bool             //dump bodies at debug level
+	metrics       MetricsRecorder  //records the job durations, nil if not instrumented
+	ctx           context.Context  //passed on to the requests
+	server        *ServerInfo      //set by Connect
+	caps          *capabilities    //support of the entries learnt by probing
+	mutatingSince Version          //first version accepting mutatingMethods, never if zero
+	jobQuerySince Version          //first version filtering the job list, never if zero
+	history       *DurationHistory //durations of the jobs seen running, nil to skip
 }
 
 func (p *Pipeline) SetCredentials(clientKey, clientSecret string) {
-	p.authenticator = authenticator(clientKey, clientSecret)
+	p.authenticator = Authenticate(clientKey, clientSecret)
 }
 
-func (p *Pipeline) SetUrl(url string) {
-	p.BaseUrl = url
+//Sends POST instead of GET to move jobs in the queue and to halt the
+//server when its version is at least since. The zero version, the default,
+//always sends GET
+func (p *Pipeline) SetMutatingMethodVersion(since Version) {
+	p.mutatingSince = since
 }
 
 //Returns a simple string representation of the Alive struct in the format:
@@ -108,14 +147,20 @@ func (a Alive) String() string {
 //Calls the alive api entry
 //TODO link to wiki
 func (p Pipeline) Alive() (alive Alive, err error) {
-	req := p.newResquest(API_ALIVE, &alive, nil)
+	req, err := p.reqAlive(&alive)
+	if err != nil {
+		return
+	}
 	_, err = p.do(req, defaultErrorHandler())
 	return
 }
 
 //Returns the list of available scripts
 func (p Pipeline) Scripts() (scripts Scripts, err error) {
-	req := p.newResquest(API_SCRIPTS, &scripts, nil)
+	req, err := p.reqScripts(&scripts)
+	if err != nil {
+		return
+	}
 	_, err = p.do(req, defaultErrorHandler())
 	if err != nil {
 		err = fmt.Errorf("Error parsing scripts XML: %v", err)
@@ -133,7 +178,10 @@ func (p Pipeline) Scripts() (scripts Scripts, err error) {
 
 //Returns the script for a given script id
 func (p Pipeline) Script(id string) (script Script, err error) {
-	req := p.newResquest(API_SCRIPT, &script, nil, id)
+	req, err := p.reqScript(&script, id)
+	if err != nil {
+		return
+	}
 	_, err = p.do(req, errorHandler(map[int]string{404: "Script " + id + " not found"}))
 	if err != nil {
 		return
@@ -179,7 +227,10 @@ func processInputsAndOptions(p Pipeline, script *Script) (err error) {
 func (p Pipeline) ScriptUrl(id string) string {
 	//This should call the server, but it just would add more overhead
 	//so it's computed here
-	req := p.newResquest(API_SCRIPT, nil, nil, id)
+	req, err := p.reqScript(nil, id)
+	if err != nil {
+		return ""
+	}
 	return req.Url
 }
 
@@ -240,7 +291,10 @@ func (p Pipeline) dataType(id string) (datatype DataType, err error) {
 			XmlDefinition: "<data type=\"string\"/>"}
 	} else {
 		xmlDefinition := new(datatypeXmlElement)
-		req := p.newResquest(API_DATATYPE, &xmlDefinition, nil, id)
+		var req *Request
+		if req, err = p.reqDatatype(&xmlDefinition, id); err != nil {
+			return
+		}
 		_, err = p.do(req, errorHandler(map[int]string{404: "Data type " + id + " not found"}))
 		if err != nil {
 			return
@@ -414,20 +468,23 @@ func multipartResultClientMaker(p Pipeline) func() doer {
 
 //Sends a JobRequest to the server
 func (p Pipeline) JobRequest(newJob JobRequest, data []byte) (job Job, err error) {
+	if p.Draining() {
+		return job, ErrDraining
+	}
 	var reqData interface{} = &newJob
-	log.Println("data len request ", len(data))
+	p.log().Debug("Sending job request", "script", newJob.Script.Id, "data", len(data))
 	//check if we have data
 	if len(data) > 0 {
-		log.Println("Sending multipart job request")
 		p.clientMaker = multipartResultClientMaker(p)
 		reqData = &MultipartData{
 			data:    RawData{&data},
 			request: newJob,
 		}
 	}
-	log.Println("Sending job request")
-	log.Println(newJob.Script.Id)
-	req := p.newResquest(API_JOBREQUEST, &job, reqData)
+	req, err := p.reqJobRequest(&job, reqData)
+	if err != nil {
+		return
+	}
 	_, err = p.do(req, errorHandler(map[int]string{
 		400: "Job request is not valid",
 	}))
@@ -437,18 +494,19 @@ func (p Pipeline) JobRequest(newJob JobRequest, data []byte) (job Job, err error
 //Sends a StylesheetParametersRequest to the server
 func (p Pipeline) StylesheetParametersRequest(paramReq StylesheetParametersRequest, data []byte) (params StylesheetParameters, err error) {
 	var reqData interface{} = &paramReq
-	log.Println("data len request ", len(data))
+	p.log().Debug("Sending stylesheet-parameters request", "data", len(data))
 	//check if we have data
 	if len(data) > 0 {
-		log.Println("Sending multipart stylesheet-parameters request")
 		p.clientMaker = multipartResultClientMaker(p)
 		reqData = &MultipartData{
 			data:    RawData{&data},
 			request: paramReq,
 		}
 	}
-	log.Println("Sending stylesheet-parameters request")
-	req := p.newResquest(API_STYLESHEET_PARAMETERS, &params, reqData)
+	req, err := p.reqStylesheetParameters(&params, reqData)
+	if err != nil {
+		return
+	}
 	_, err = p.do(req, errorHandler(map[int]string{
 		400: "Stylesheet-parameters request is not valid",
 	}))
@@ -475,8 +533,18 @@ func (p Pipeline) StylesheetParametersRequest(paramReq StylesheetParametersReque
 
 //Sends a Job query to the webservice
 func (p Pipeline) Job(id string, messageSequence int) (job Job, err error) {
-	req := p.newResquest(API_JOB, &job, nil, id, messageSequence)
-	_, err = p.do(req, errorHandler(map[int]string{
+	job, _, err = p.jobWithStatus(id, messageSequence)
+	return
+}
+
+//Job along with the status of the response, e.g. to tell a missing job
+//from a failed request
+func (p Pipeline) jobWithStatus(id string, messageSequence int) (job Job, status int, err error) {
+	req, err := p.reqJob(&job, id, messageSequence)
+	if err != nil {
+		return
+	}
+	status, err = p.do(req, errorHandler(map[int]string{
 		404: "Job " + id + " not found",
 	}))
 	return
@@ -484,7 +552,10 @@ func (p Pipeline) Job(id string, messageSequence int) (job Job, err error) {
 
 //Sends a Job query to the webservice
 func (p Pipeline) Batch(id string) (jobs Jobs, err error) {
-	req := p.newResquest(API_BATCH, &jobs, nil, id)
+	req, err := p.reqBatch(&jobs, id)
+	if err != nil {
+		return
+	}
 	_, err = p.do(req, errorHandler(map[int]string{
 		404: "Job " + id + " not found",
 	}))
@@ -493,14 +564,20 @@ func (p Pipeline) Batch(id string) (jobs Jobs, err error) {
 
 //Sends a request to the server in order to get all the jobs
 func (p Pipeline) Jobs() (jobs Jobs, err error) {
-	req := p.newResquest(API_JOBS, &jobs, nil)
+	req, err := p.reqJobs(&jobs)
+	if err != nil {
+		return
+	}
 	_, err = p.do(req, defaultErrorHandler())
 	return
 }
 
 //Deletes a job
 func (p Pipeline) DeleteJob(id string) (ok bool, err error) {
-	req := p.newResquest(API_DEL_JOB, nil, nil, id)
+	req, err := p.reqDelJob(id)
+	if err != nil {
+		return
+	}
 	_, err = p.do(req, errorHandler(map[int]string{
 		404: "Job " + id + " not found",
 	}))
@@ -512,7 +589,10 @@ func (p Pipeline) DeleteJob(id string) (ok bool, err error) {
 
 //Deletes a batch of jobs
 func (p Pipeline) DeleteBatch(id string) (ok bool, err error) {
-	req := p.newResquest(API_DEL_BATCH, nil, nil, id)
+	req, err := p.reqDelBatch(id)
+	if err != nil {
+		return
+	}
 	_, err = p.do(req, errorHandler(map[int]string{
 		404: "Job batch " + id + " not found",
 	}))
@@ -535,7 +615,10 @@ func resultClientMaker(p Pipeline) func() doer {
 func (p Pipeline) Results(id string, w io.Writer) (ok bool, err error) {
 	//check whether results are available
 	job := Job{}
-	req := p.newResquest(API_JOB, &job, nil, id, math.MaxInt32)
+	req, err := p.reqJob(&job, id, math.MaxInt32)
+	if err != nil {
+		return
+	}
 	_, err = p.do(req, errorHandler(map[int]string{
 		404: "Job " + id + " not found",
 	}))
@@ -545,7 +628,9 @@ func (p Pipeline) Results(id string, w io.Writer) (ok bool, err error) {
 		} else {
 			//override the client maker
 			p.clientMaker = resultClientMaker(p)
-			req = p.newResquest(API_RESULT, w, nil, id)
+			if req, err = p.reqResult(w, id); err != nil {
+				return false, err
+			}
 			_, err = p.do(req, errorHandler(map[int]string{
 				404: "Job " + id + " not found",
 			}))
@@ -569,7 +654,10 @@ func rawClientMaker(p Pipeline) func() doer {
 func (p Pipeline) Log(id string) (data []byte, err error) {
 	p.clientMaker = rawClientMaker(p)
 	rd := &RawData{Data: new([]byte)}
-	req := p.newResquest(API_LOG, rd, nil, id)
+	req, err := p.reqLog(rd, id)
+	if err != nil {
+		return
+	}
 	_, err = p.do(req, errorHandler(map[int]string{
 		404: "Job " + id + " not found",
 	}))
@@ -583,15 +671,24 @@ func (p Pipeline) Log(id string) (data []byte, err error) {
 //Halts the ws
 func (p Pipeline) Halt(key string) error {
 	//override the client maker
-	req := p.newResquest(API_HALT, nil, nil, key)
-	_, err := p.do(req, defaultErrorHandler())
+	req, err := p.reqHalt(key)
+	if err != nil {
+		return err
+	}
+	if err := p.negotiateMethod(req); err != nil {
+		return err
+	}
+	_, err = p.do(req, defaultErrorHandler())
 	return err
 }
 
 //Returns the list of clients
 func (p Pipeline) Clients() (clients []Client, err error) {
 	clientsStr := Clients{}
-	req := p.newResquest(API_CLIENTS, &clientsStr, nil)
+	req, err := p.reqClients(&clientsStr)
+	if err != nil {
+		return
+	}
 	_, err = p.do(req, defaultErrorHandler())
 	if err != nil {
 		return
@@ -600,18 +697,35 @@ func (p Pipeline) Clients() (clients []Client, err error) {
 	return
 }
 
-//Creates a new client
+//Creates a new client, a strong secret is generated if none is given
 func (p Pipeline) NewClient(in Client) (out Client, err error) {
-	req := p.newResquest(API_NEWCLIENT, &out, &in)
+	if in.Secret == "" {
+		if in.Secret, err = GenerateSecret(DEFAULT_SECRET_LENGTH); err != nil {
+			return
+		}
+	}
+	if err = in.Validate(); err != nil {
+		return
+	}
+	req, err := p.reqNewClient(&out, &in)
+	if err != nil {
+		return
+	}
 	_, err = p.do(req, errorHandler(map[int]string{
 		400: fmt.Sprintf("Client with id %v may already exist", in.Id),
 	}))
+	if err == nil && out.Secret == "" {
+		out.Secret = in.Secret
+	}
 	return
 }
 
 //Retrieves a client using the its id
 func (p Pipeline) Client(id string) (out Client, err error) {
-	req := p.newResquest(API_CLIENT, &out, nil, id)
+	req, err := p.reqClient(&out, id)
+	if err != nil {
+		return
+	}
 	_, err = p.do(req, errorHandler(map[int]string{
 		404: "Client with id " + id + " not found",
 	}))
@@ -620,7 +734,10 @@ func (p Pipeline) Client(id string) (out Client, err error) {
 
 //Deletes a client
 func (p Pipeline) DeleteClient(id string) (ok bool, err error) {
-	req := p.newResquest(API_DELETECLIENT, nil, nil, id)
+	req, err := p.reqDeleteClient(id)
+	if err != nil {
+		return
+	}
 	_, err = p.do(req, errorHandler(map[int]string{
 		404: "Client with id " + id + " not found",
 	}))
@@ -630,11 +747,31 @@ func (p Pipeline) DeleteClient(id string) (ok bool, err error) {
 	return
 }
 
-//Modifies a client with the new data TODO:include the id in the client structure
-func (p Pipeline) ModifyClient(in Client, id string) (out Client, err error) {
-	req := p.newResquest(API_MODIFYCLIENT, &out, &in, id)
+//Modifies the client identified by in.Id. Only the non empty fields of in
+//are changed, the rest are kept as they are in the server. The whole client
+//is sent back, so when the server doesn't return the secret in.Secret is
+//required, otherwise ErrSecretUnknown is returned instead of clearing it
+func (p Pipeline) ModifyClient(in Client) (out Client, err error) {
+	if in.Id == "" {
+		return out, errors.New("Client id is required")
+	}
+	current, err := p.Client(in.Id)
+	if err != nil {
+		return
+	}
+	merged := mergeClient(current, in)
+	if err = merged.Validate(); err != nil {
+		return
+	}
+	if merged.Secret == "" {
+		return out, ErrSecretUnknown
+	}
+	req, err := p.reqModifyClient(&out, &merged, in.Id)
+	if err != nil {
+		return
+	}
 	_, err = p.do(req, errorHandler(map[int]string{
-		404: "Client with id " + id + " not found",
+		404: "Client with id " + in.Id + " not found",
 	}))
 	return
 }
@@ -642,7 +779,10 @@ func (p Pipeline) ModifyClient(in Client, id string) (out Client, err error) {
 //Retrieves the list of different properties which describes the framework configuration
 func (p Pipeline) Properties() (out []Property, err error) {
 	props := Properties{}
-	req := p.newResquest(API_PROPERTIES, &props, nil)
+	req, err := p.reqProperties(&props)
+	if err != nil {
+		return
+	}
 	_, err = p.do(req, defaultErrorHandler())
 	if err != nil {
 		return
@@ -652,7 +792,10 @@ func (p Pipeline) Properties() (out []Property, err error) {
 
 //Gets the physical size of the jobs
 func (p Pipeline) Sizes() (sizes JobSizes, err error) {
-	req := p.newResquest(API_SIZE, &sizes, nil)
+	req, err := p.reqSize(&sizes)
+	if err != nil {
+		return
+	}
 	_, err = p.do(req, defaultErrorHandler())
 	if err != nil {
 		return
@@ -663,7 +806,10 @@ func (p Pipeline) Sizes() (sizes JobSizes, err error) {
 //Gets execution queue
 func (p Pipeline) Queue() (jobs []QueueJob, err error) {
 	queue := Queue{}
-	req := p.newResquest(API_QUEUE, &queue, nil)
+	req, err := p.reqQueue(&queue)
+	if err != nil {
+		return
+	}
 	_, err = p.do(req, defaultErrorHandler())
 	if err != nil {
 		return
@@ -674,7 +820,13 @@ func (p Pipeline) Queue() (jobs []QueueJob, err error) {
 
 func (p Pipeline) MoveUp(jobId string) (jobs []QueueJob, err error) {
 	queue := Queue{}
-	req := p.newResquest(API_MOVE_UP, &queue, nil, jobId)
+	req, err := p.reqMoveUp(&queue, jobId)
+	if err != nil {
+		return
+	}
+	if err = p.negotiateMethod(req); err != nil {
+		return
+	}
 	_, err = p.do(req, defaultErrorHandler())
 	if err != nil {
 		return
@@ -685,7 +837,13 @@ func (p Pipeline) MoveUp(jobId string) (jobs []QueueJob, err error) {
 
 func (p Pipeline) MoveDown(jobId string) (jobs []QueueJob, err error) {
 	queue := Queue{}
-	req := p.newResquest(API_MOVE_DOWN, &queue, nil, jobId)
+	req, err := p.reqMoveDown(&queue, jobId)
+	if err != nil {
+		return
+	}
+	if err = p.negotiateMethod(req); err != nil {
+		return
+	}
 	_, err = p.do(req, defaultErrorHandler())
 	if err != nil {
 		return
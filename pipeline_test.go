@@ -132,7 +132,7 @@ func TestAlive(t *testing.T) {
 func TestReqScripts(t *testing.T) {
 	var scripts Scripts
 	pipeline := createPipeline(emptyClientMock)
-	r := pipeline.newResquest(API_SCRIPTS, &scripts, nil)
+	r, _ := pipeline.reqScripts(&scripts)
 	if r.Url != "base/scripts" {
 		t.Errorf("Scripts path set to %v", r.Url)
 	}
@@ -157,7 +157,7 @@ func TestScripts(t *testing.T) {
 func TestReqScript(t *testing.T) {
 	var script Script
 	pipeline := createPipeline(emptyClientMock)
-	r := pipeline.newResquest(API_SCRIPT, &script, nil, "test")
+	r, _ := pipeline.reqScript(&script, "test")
 	if r.Url != "base/scripts/test" {
 		t.Errorf("Scripts path set to %v", r.Url)
 	}
@@ -280,7 +280,7 @@ func TestJobs(t *testing.T) {
 		t.Errorf("Error not nil %v", err)
 	}
 	if len(res.Jobs) != 4 {
-		t.Errorf("Wrong jobs size", res.Jobs)
+		t.Errorf("Wrong jobs size %v", res.Jobs)
 	}
 	for idx, job := range res.Jobs {
 		jobId := fmt.Sprintf(idTemp, idx+1)
@@ -298,7 +298,7 @@ func TestBatch(t *testing.T) {
 		t.Errorf("Error not nil %v", err)
 	}
 	if len(res.Jobs) != 4 {
-		t.Errorf("Wrong jobs size", res.Jobs)
+		t.Errorf("Wrong jobs size %v", res.Jobs)
 	}
 	for idx, job := range res.Jobs {
 		jobId := fmt.Sprintf(idTemp, idx+1)
@@ -353,9 +353,11 @@ func TestDeleteJobError(t *testing.T) {
 
 func TestAutheticator(t *testing.T) {
 	var alive Alive
-	r := Pipeline{}.newResquest(API_ALIVE, &alive, nil)
-	authenticator("cli", "shhh")(r)
-	url := r.Url
+	r, _ := Pipeline{}.reqAlive(&alive)
+	url, err := Signer{ClientKey: "cli", ClientSecret: "shhh"}.Sign(r.Url)
+	if err != nil {
+		t.Errorf("Unexpected error %v", err)
+	}
 	if !strings.Contains(url, "sign") {
 		t.Errorf("No sign in url %v", url)
 	}
@@ -409,7 +411,7 @@ func TestHaltError(t *testing.T) {
 	}
 }
 func TestNewClient(t *testing.T) {
-	client := Client{Id: "id"}
+	client := Client{Id: "id", Role: ROLE_CLIENTAPP}
 	pipeline := createPipeline(structClientMock(client, 201))
 	cout, err := pipeline.NewClient(client)
 	if err != nil {
@@ -420,7 +422,7 @@ func TestNewClient(t *testing.T) {
 	}
 }
 func TestClientExists(t *testing.T) {
-	client := Client{Id: "id"}
+	client := Client{Id: "id", Role: ROLE_CLIENTAPP}
 	pipeline := createPipeline(structClientMock(client, 400))
 	_, err := pipeline.NewClient(client)
 	if err == nil {
@@ -498,9 +500,9 @@ func TestDeleteClientNotFound(t *testing.T) {
 }
 
 func TestModifyClient(t *testing.T) {
-	client := Client{Id: "id", Secret: "other"}
+	client := Client{Id: "id", Secret: "other", Role: ROLE_ADMIN}
 	pipeline := createPipeline(structClientMock(client, 200))
-	cout, err := pipeline.ModifyClient(client, "myclient!")
+	cout, err := pipeline.ModifyClient(Client{Id: "id", Contact: "me@example.org"})
 	if err != nil {
 		t.Errorf("Client returned error but should be ok")
 	}
@@ -512,7 +514,7 @@ func TestModifyClient(t *testing.T) {
 func TestModifyClientNotFound(t *testing.T) {
 	client := Client{Id: "id"}
 	pipeline := createPipeline(structClientMock(client, 404))
-	_, err := pipeline.ModifyClient(client, "that one, you know...")
+	_, err := pipeline.ModifyClient(client)
 	if err == nil {
 		t.Errorf("Not found should have been thrown")
 	}
@@ -662,3 +664,53 @@ func TestMoveDownNotFound(t *testing.T) {
 		t.Errorf("Expected error not thrown")
 	}
 }
+
+func TestIdempotentEntries(t *testing.T) {
+	for _, entry := range []string{API_MOVE_UP, API_MOVE_DOWN, API_HALT, API_JOBREQUEST, API_NEWCLIENT, "unknown"} {
+		if idempotent(entry) {
+			t.Errorf("%v should not be idempotent", entry)
+		}
+	}
+	for _, entry := range []string{API_ALIVE, API_JOB, API_DEL_JOB, API_MODIFYCLIENT} {
+		if !idempotent(entry) {
+			t.Errorf("%v should be idempotent", entry)
+		}
+	}
+}
+
+func TestNegotiateMethod(t *testing.T) {
+	queue := `<queue xmlns="http://www.daisy.org/ns/pipeline/data"/>`
+	routes := map[string]mockHandler{
+		"GET alive":        xmlRoute(aliveXml, 200),
+		"GET queue/up/":    xmlRoute(queue, 200),
+		"POST queue/up/":   xmlRoute(queue, 200),
+		"POST admin/halt/": xmlRoute("", 204),
+	}
+	recorder := &mockRecorder{}
+	pipeline := createPipeline(routedClientMock(routes, recorder))
+	if _, err := pipeline.MoveUp("job"); err != nil {
+		t.Errorf("Unexpected error %v", err)
+	}
+	if recorder.count("GET queue/up/job") != 1 || recorder.count("GET alive") != 0 {
+		t.Errorf("Legacy method not used %v", recorder.calls)
+	}
+
+	pipeline.SetMutatingMethodVersion(Version{Major: 1, Minor: 6})
+	if _, err := pipeline.MoveUp("job"); err != nil {
+		t.Errorf("Unexpected error %v", err)
+	}
+	if err := pipeline.Halt("key"); err != nil {
+		t.Errorf("Unexpected error %v", err)
+	}
+	if recorder.count("POST queue/up/job") != 1 || recorder.count("POST admin/halt/key") != 1 {
+		t.Errorf("Mutating method not used %v", recorder.calls)
+	}
+
+	pipeline.SetMutatingMethodVersion(Version{Major: 1, Minor: 7})
+	if _, err := pipeline.MoveUp("job"); err != nil {
+		t.Errorf("Unexpected error %v", err)
+	}
+	if recorder.count("GET queue/up/job") != 2 {
+		t.Errorf("Legacy method not used with older servers %v", recorder.calls)
+	}
+}
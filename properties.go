@@ -0,0 +1,157 @@
+package pipeline
+
+import (
+	"fmt"
+	"sort"
+	"strconv"
+	"time"
+)
+
+//Well known framework properties
+const (
+	PROP_WS_HOST           = "org.daisy.pipeline.ws.host"
+	PROP_WS_PORT           = "org.daisy.pipeline.ws.port"
+	PROP_WS_PATH           = "org.daisy.pipeline.ws.path"
+	PROP_WS_LOCALFS        = "org.daisy.pipeline.ws.localfs"
+	PROP_WS_AUTHENTICATION = "org.daisy.pipeline.ws.authentication"
+	PROP_WS_CLEANUP        = "org.daisy.pipeline.ws.cleanuptimeout"
+	PROP_PROCS             = "org.daisy.pipeline.procs"
+)
+
+//Typed view of the well known properties, the zero value of a field is used
+//when the server doesn't report the property
+type FrameworkSettings struct {
+	Host           string        //Web service host
+	Port           int           //Web service port
+	Path           string        //Web service path
+	LocalFs        bool          //Jobs may access the server file system
+	Authentication bool          //Requests must be signed
+	CleanupTimeout time.Duration //Time after which finished jobs are removed
+	MaxWorkers     int           //Jobs run at the same time
+}
+
+//Framework properties indexed by name
+type PropertySet struct {
+	Properties []Property
+	byName     map[string]Property
+}
+
+//Indexes the properties, if a name is repeated the last one wins
+func NewPropertySet(props []Property) PropertySet {
+	set := PropertySet{Properties: props, byName: make(map[string]Property)}
+	for _, prop := range props {
+		set.byName[prop.Name] = prop
+	}
+	return set
+}
+
+//Gets the framework properties indexed by name
+func (p Pipeline) PropertySet() (PropertySet, error) {
+	props, err := p.Properties()
+	if err != nil {
+		return PropertySet{}, err
+	}
+	return NewPropertySet(props), nil
+}
+
+//Returns the property with the given name
+func (s PropertySet) Get(name string) (prop Property, ok bool) {
+	prop, ok = s.byName[name]
+	return
+}
+
+//Returns the value of the property, empty if it's not set
+func (s PropertySet) Value(name string) string {
+	return s.byName[name].Value
+}
+
+//Returns the value as an integer, ok is false if the property is not set
+func (s PropertySet) Int(name string) (value int, ok bool, err error) {
+	prop, ok := s.byName[name]
+	if !ok || prop.Value == "" {
+		return 0, false, nil
+	}
+	value, err = strconv.Atoi(prop.Value)
+	if err != nil {
+		err = fmt.Errorf("Property %v is not an integer: %q", name, prop.Value)
+	}
+	return
+}
+
+//Returns the value as a boolean, ok is false if the property is not set
+func (s PropertySet) Bool(name string) (value bool, ok bool, err error) {
+	prop, ok := s.byName[name]
+	if !ok || prop.Value == "" {
+		return false, false, nil
+	}
+	value, err = strconv.ParseBool(prop.Value)
+	if err != nil {
+		err = fmt.Errorf("Property %v is not a boolean: %q", name, prop.Value)
+	}
+	return
+}
+
+//Returns the well known properties, err is the first property that couldn't
+//be parsed, the rest of the fields are filled anyway
+func (s PropertySet) Settings() (settings FrameworkSettings, err error) {
+	keep := func(e error) {
+		if err == nil {
+			err = e
+		}
+	}
+	settings.Host = s.Value(PROP_WS_HOST)
+	settings.Path = s.Value(PROP_WS_PATH)
+	var e error
+	settings.Port, _, e = s.Int(PROP_WS_PORT)
+	keep(e)
+	settings.LocalFs, _, e = s.Bool(PROP_WS_LOCALFS)
+	keep(e)
+	settings.Authentication, _, e = s.Bool(PROP_WS_AUTHENTICATION)
+	keep(e)
+	cleanup, _, e := s.Int(PROP_WS_CLEANUP)
+	keep(e)
+	//expressed in seconds
+	settings.CleanupTimeout = time.Duration(cleanup) * time.Second
+	settings.MaxWorkers, _, e = s.Int(PROP_PROCS)
+	keep(e)
+	return
+}
+
+//Difference of a property between two servers
+type PropertyDiff struct {
+	Name      string
+	Left      string //Value in the first server
+	Right     string //Value in the second server
+	OnlyLeft  bool   //Not set in the second server
+	OnlyRight bool   //Not set in the first server
+}
+
+func (d PropertyDiff) String() string {
+	switch {
+	case d.OnlyLeft:
+		return fmt.Sprintf("- %v=%v", d.Name, d.Left)
+	case d.OnlyRight:
+		return fmt.Sprintf("+ %v=%v", d.Name, d.Right)
+	}
+	return fmt.Sprintf("~ %v: %v -> %v", d.Name, d.Left, d.Right)
+}
+
+//Returns the properties that differ between both sets, sorted by name
+func DiffProperties(left, right PropertySet) (diffs []PropertyDiff) {
+	for name, l := range left.byName {
+		r, ok := right.byName[name]
+		switch {
+		case !ok:
+			diffs = append(diffs, PropertyDiff{Name: name, Left: l.Value, OnlyLeft: true})
+		case l.Value != r.Value:
+			diffs = append(diffs, PropertyDiff{Name: name, Left: l.Value, Right: r.Value})
+		}
+	}
+	for name, r := range right.byName {
+		if _, ok := left.byName[name]; !ok {
+			diffs = append(diffs, PropertyDiff{Name: name, Right: r.Value, OnlyRight: true})
+		}
+	}
+	sort.Slice(diffs, func(i, j int) bool { return diffs[i].Name < diffs[j].Name })
+	return
+}
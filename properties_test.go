@@ -0,0 +1,80 @@
+package pipeline
+
+import (
+	"testing"
+	"time"
+)
+
+var serverProperties = []Property{
+	{Name: PROP_WS_HOST, Value: "localhost"},
+	{Name: PROP_WS_PORT, Value: "8181"},
+	{Name: PROP_WS_PATH, Value: "/ws"},
+	{Name: PROP_WS_LOCALFS, Value: "true"},
+	{Name: PROP_WS_AUTHENTICATION, Value: "false"},
+	{Name: PROP_WS_CLEANUP, Value: "3600"},
+	{Name: PROP_PROCS, Value: "4"},
+}
+
+func TestPropertySetSettings(t *testing.T) {
+	pipeline := createPipeline(structClientMock(Properties{Properties: serverProperties}, 200))
+	set, err := pipeline.PropertySet()
+	if err != nil {
+		t.Fatalf("Unexpected error %v", err)
+	}
+	settings, err := set.Settings()
+	if err != nil {
+		t.Errorf("Unexpected error %v", err)
+	}
+	exp := FrameworkSettings{
+		Host:           "localhost",
+		Port:           8181,
+		Path:           "/ws",
+		LocalFs:        true,
+		CleanupTimeout: time.Hour,
+		MaxWorkers:     4,
+	}
+	if settings != exp {
+		t.Errorf(T_STRING, "settings", exp, settings)
+	}
+	if prop, ok := set.Get(PROP_WS_PORT); !ok || prop.Value != "8181" {
+		t.Errorf("Wrong property %+v", prop)
+	}
+	if _, ok, err := set.Int("missing"); ok || err != nil {
+		t.Errorf("Missing property should not be ok")
+	}
+}
+
+func TestPropertySetSettingsError(t *testing.T) {
+	set := NewPropertySet([]Property{{Name: PROP_WS_PORT, Value: "http"}, {Name: PROP_PROCS, Value: "2"}})
+	settings, err := set.Settings()
+	if err == nil {
+		t.Errorf("Expected error not thrown")
+	}
+	if settings.MaxWorkers != 2 {
+		t.Errorf("Valid settings should be filled %+v", settings)
+	}
+}
+
+func TestDiffProperties(t *testing.T) {
+	left := NewPropertySet(serverProperties)
+	right := NewPropertySet([]Property{
+		{Name: PROP_WS_HOST, Value: "localhost"},
+		{Name: PROP_WS_PORT, Value: "8182"},
+		{Name: "extra", Value: "x"},
+	})
+	diffs := DiffProperties(left, right)
+	if len(diffs) != 7 {
+		t.Fatalf(T_STRING, "diffs", 7, len(diffs))
+	}
+	if diffs[0].String() != "+ extra=x" {
+		t.Errorf(T_STRING, "diff", "+ extra=x", diffs[0])
+	}
+	for _, d := range diffs {
+		if d.Name == PROP_WS_PORT && d.String() != "~ org.daisy.pipeline.ws.port: 8181 -> 8182" {
+			t.Errorf("Wrong diff %v", d)
+		}
+		if d.Name == PROP_PROCS && !d.OnlyLeft {
+			t.Errorf("Wrong diff %v", d)
+		}
+	}
+}
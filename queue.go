@@ -0,0 +1,90 @@
+package pipeline
+
+import (
+	"fmt"
+	"strings"
+)
+
+//Parses a priority name, case insensitive
+func ParsePriority(s string) (Priority, error) {
+	p := Priority(strings.ToLower(strings.TrimSpace(s)))
+	if !p.Valid() {
+		return "", fmt.Errorf("Invalid priority %q, expected low, medium or high", s)
+	}
+	return p, nil
+}
+
+//Tells whether the priority is one of the known ones
+func (p Priority) Valid() bool {
+	return p.Rank() > 0
+}
+
+//Returns 1, 2 and 3 for low, medium and high priorities, 0 otherwise
+func (p Priority) Rank() int {
+	switch Priority(strings.ToLower(string(p))) {
+	case PRIORITY_LOW:
+		return 1
+	case PRIORITY_MEDIUM:
+		return 2
+	case PRIORITY_HIGH:
+		return 3
+	}
+	return 0
+}
+
+//Returns the position of the job in the queue, -1 if it's not there
+func queueIndex(jobs []QueueJob, jobId string) int {
+	for i, job := range jobs {
+		if job.Id == jobId {
+			return i
+		}
+	}
+	return -1
+}
+
+//Moves the job up or down in the queue until it reaches the given position,
+//0 being the head of the queue. Positions out of the queue are taken as the
+//closest end. Returns the final queue
+func (p Pipeline) MoveTo(jobId string, position int) (jobs []QueueJob, err error) {
+	jobs, err = p.Queue()
+	if err != nil {
+		return
+	}
+	current := queueIndex(jobs, jobId)
+	if current < 0 {
+		return jobs, fmt.Errorf("Job %v not found in the queue", jobId)
+	}
+	if position < 0 {
+		position = 0
+	}
+	if position >= len(jobs) {
+		position = len(jobs) - 1
+	}
+	//the queue may change meanwhile, don't chase the position forever
+	for moves := 2 * len(jobs); current != position; moves-- {
+		if moves == 0 {
+			return jobs, fmt.Errorf("Job %v couldn't reach position %v", jobId, position)
+		}
+		if current > position {
+			jobs, err = p.MoveUp(jobId)
+		} else {
+			jobs, err = p.MoveDown(jobId)
+		}
+		if err != nil {
+			return
+		}
+		next := queueIndex(jobs, jobId)
+		if next < 0 {
+			//the job left the queue, probably it started running
+			return jobs, fmt.Errorf("Job %v not found in the queue", jobId)
+		}
+		if next == current {
+			return jobs, fmt.Errorf("Job %v can't be moved from position %v", jobId, current)
+		}
+		current = next
+		if position >= len(jobs) {
+			position = len(jobs) - 1
+		}
+	}
+	return
+}
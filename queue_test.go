@@ -0,0 +1,105 @@
+package pipeline
+
+import (
+	"fmt"
+	"strings"
+	"testing"
+
+	"github.com/capitancambio/restclient"
+)
+
+//Queue kept by the mock, moves swap the jobs
+type mockQueue struct {
+	ids []string
+}
+
+func (q *mockQueue) xml() string {
+	jobs := ""
+	for _, id := range q.ids {
+		jobs += fmt.Sprintf(`<job id="%v" jobPriority="high" clientPriority="low"/>`, id)
+	}
+	return `<queue xmlns="http://www.daisy.org/ns/pipeline/data">` + jobs + `</queue>`
+}
+
+func (q *mockQueue) move(delta int) mockHandler {
+	return func(rr *restclient.RequestResponse) (string, int) {
+		id := rr.Url[strings.LastIndex(rr.Url, "/")+1:]
+		i := queueIndex(queueJobs(q.ids), id)
+		if j := i + delta; i >= 0 && j >= 0 && j < len(q.ids) {
+			q.ids[i], q.ids[j] = q.ids[j], q.ids[i]
+		}
+		return q.xml(), 200
+	}
+}
+
+func queueJobs(ids []string) (jobs []QueueJob) {
+	for _, id := range ids {
+		jobs = append(jobs, QueueJob{Id: id})
+	}
+	return
+}
+
+func queuePipeline(q *mockQueue) Pipeline {
+	return createPipeline(routedClientMock(map[string]mockHandler{
+		"GET queue": func(*restclient.RequestResponse) (string, int) {
+			return q.xml(), 200
+		},
+		"GET queue/up/":   q.move(-1),
+		"GET queue/down/": q.move(1),
+	}, &mockRecorder{}))
+}
+
+func TestMoveTo(t *testing.T) {
+	q := &mockQueue{ids: []string{"a", "b", "c", "d"}}
+	pipeline := queuePipeline(q)
+	jobs, err := pipeline.MoveTo("d", 1)
+	if err != nil {
+		t.Fatalf("Unexpected error %v", err)
+	}
+	if queueIndex(jobs, "d") != 1 || strings.Join(q.ids, "") != "adbc" {
+		t.Errorf("Wrong queue %v", q.ids)
+	}
+	if jobs[0].JobPriority != PRIORITY_HIGH || jobs[0].ClientPriority != PRIORITY_LOW {
+		t.Errorf("Wrong priorities %+v", jobs[0])
+	}
+	if _, err = pipeline.MoveTo("a", 10); err != nil {
+		t.Fatalf("Unexpected error %v", err)
+	}
+	if strings.Join(q.ids, "") != "dbca" {
+		t.Errorf("Wrong queue %v", q.ids)
+	}
+	if _, err = pipeline.MoveTo("x", 0); err == nil {
+		t.Errorf("Expected error not thrown")
+	}
+}
+
+func TestMoveToStuck(t *testing.T) {
+	q := &mockQueue{ids: []string{"a", "b"}}
+	pipeline := createPipeline(routedClientMock(map[string]mockHandler{
+		"GET queue": func(*restclient.RequestResponse) (string, int) {
+			return q.xml(), 200
+		},
+		"GET queue/up/": func(*restclient.RequestResponse) (string, int) {
+			return q.xml(), 200
+		},
+	}, &mockRecorder{}))
+	if _, err := pipeline.MoveTo("b", 0); err == nil {
+		t.Errorf("Expected error not thrown")
+	}
+}
+
+func TestParsePriority(t *testing.T) {
+	p, err := ParsePriority(" High")
+	if err != nil || p != PRIORITY_HIGH {
+		t.Errorf(T_STRING, "priority", PRIORITY_HIGH, p)
+	}
+	if _, err := ParsePriority("urgent"); err == nil {
+		t.Errorf("Expected error not thrown")
+	}
+	if !PRIORITY_LOW.Valid() || Priority("").Valid() {
+		t.Errorf("Wrong validation")
+	}
+	if PRIORITY_LOW.Rank() >= PRIORITY_MEDIUM.Rank() || PRIORITY_MEDIUM.Rank() >= PRIORITY_HIGH.Rank() {
+		t.Errorf("Wrong priority order")
+	}
+}
@@ -0,0 +1,193 @@
+package pipeline
+
+import (
+	"encoding/json"
+	"fmt"
+	"sort"
+	"strings"
+)
+
+//Client as described in the desired state
+type DesiredClient struct {
+	Id       string   `json:"id" yaml:"id"`
+	Role     Role     `json:"role" yaml:"role"`
+	Contact  string   `json:"contact,omitempty" yaml:"contact,omitempty"`   //Left untouched if empty
+	Priority Priority `json:"priority,omitempty" yaml:"priority,omitempty"` //Left untouched if empty
+}
+
+func (d DesiredClient) client() Client {
+	return Client{Id: d.Id, Role: d.Role, Contact: d.Contact, Priority: d.Priority}
+}
+
+//Desired state of the web service clients. The yaml tags allow decoding
+//it with any yaml library, ParseClientsConfig reads json
+type ClientsConfig struct {
+	Clients   []DesiredClient `json:"clients" yaml:"clients"`
+	Prune     bool            `json:"prune,omitempty" yaml:"prune,omitempty"`         //Delete the clients not in the list
+	Protected []string        `json:"protected,omitempty" yaml:"protected,omitempty"` //Ids never deleted by pruning
+}
+
+//Parses a json desired state and validates it
+func ParseClientsConfig(data []byte) (cfg ClientsConfig, err error) {
+	if err = json.Unmarshal(data, &cfg); err != nil {
+		return cfg, fmt.Errorf("Error parsing clients config: %v", err)
+	}
+	return cfg, cfg.Validate()
+}
+
+//Checks every client and that ids are unique
+func (c ClientsConfig) Validate() error {
+	seen := make(map[string]bool)
+	for _, d := range c.Clients {
+		if err := d.client().Validate(); err != nil {
+			return err
+		}
+		if seen[d.Id] {
+			return fmt.Errorf("Client %v is listed twice", d.Id)
+		}
+		seen[d.Id] = true
+	}
+	return nil
+}
+
+//Kinds of changes done by the reconciliation
+type ClientAction string
+
+const (
+	CLIENT_CREATE ClientAction = "create"
+	CLIENT_UPDATE ClientAction = "update"
+	CLIENT_DELETE ClientAction = "delete"
+)
+
+//Change needed to bring a client to the desired state
+type ClientChange struct {
+	Action  ClientAction
+	Id      string
+	Desired Client   //Empty for deletions
+	Current Client   //Empty for creations
+	Fields  []string //Fields that differ, for updates
+}
+
+func (c ClientChange) String() string {
+	if c.Action == CLIENT_UPDATE {
+		return fmt.Sprintf("%v %v (%v)", c.Action, c.Id, strings.Join(c.Fields, ", "))
+	}
+	return fmt.Sprintf("%v %v", c.Action, c.Id)
+}
+
+//Changes needed to reach the desired state
+type ClientPlan struct {
+	Changes []ClientChange
+}
+
+//Tells whether the server differs from the desired state
+func (p ClientPlan) Drift() bool {
+	return len(p.Changes) > 0
+}
+
+//One change per line
+func (p ClientPlan) String() string {
+	lines := []string{}
+	for _, c := range p.Changes {
+		lines = append(lines, c.String())
+	}
+	return strings.Join(lines, "\n")
+}
+
+//Returns the fields of current that differ from desired
+func clientDiff(current, desired Client) (fields []string) {
+	if current.Role != desired.Role {
+		fields = append(fields, "role")
+	}
+	//empty optional fields are left as they are in the server
+	if desired.Contact != "" && current.Contact != desired.Contact {
+		fields = append(fields, "contact")
+	}
+	if desired.Priority != "" && !strings.EqualFold(string(current.Priority), string(desired.Priority)) {
+		fields = append(fields, "priority")
+	}
+	return
+}
+
+//Compares the desired state with the clients in the server
+func (p Pipeline) PlanClients(cfg ClientsConfig) (plan ClientPlan, err error) {
+	if err = cfg.Validate(); err != nil {
+		return
+	}
+	clients, err := p.Clients()
+	if err != nil {
+		return
+	}
+	current := make(map[string]Client)
+	for _, c := range clients {
+		current[c.Id] = c
+	}
+	desired := make(map[string]bool)
+	for _, d := range cfg.Clients {
+		desired[d.Id] = true
+		want := d.client()
+		have, ok := current[d.Id]
+		if !ok {
+			plan.Changes = append(plan.Changes, ClientChange{Action: CLIENT_CREATE, Id: d.Id, Desired: want})
+			continue
+		}
+		if fields := clientDiff(have, want); len(fields) > 0 {
+			plan.Changes = append(plan.Changes, ClientChange{Action: CLIENT_UPDATE, Id: d.Id, Desired: want, Current: have, Fields: fields})
+		}
+	}
+	if cfg.Prune {
+		var deletions []ClientChange
+		for id, have := range current {
+			if !desired[id] && !containsString(cfg.Protected, id) {
+				deletions = append(deletions, ClientChange{Action: CLIENT_DELETE, Id: id, Current: have})
+			}
+		}
+		sort.Slice(deletions, func(i, j int) bool { return deletions[i].Id < deletions[j].Id })
+		plan.Changes = append(plan.Changes, deletions...)
+	}
+	return
+}
+
+//Outcome of a change
+type ClientChangeResult struct {
+	Change ClientChange
+	Client Client //Client returned by the server, holds the generated secret for creations
+	Err    error
+}
+
+//Applies the changes of the plan, errors don't stop the remaining changes.
+//Updates fail with ErrSecretUnknown if the server doesn't return the secret
+//of the client, as the desired state has none to send
+func (p Pipeline) ApplyClients(plan ClientPlan) (results []ClientChangeResult, err error) {
+	failed := 0
+	for _, change := range plan.Changes {
+		res := ClientChangeResult{Change: change}
+		switch change.Action {
+		case CLIENT_CREATE:
+			res.Client, res.Err = p.NewClient(change.Desired)
+		case CLIENT_UPDATE:
+			res.Client, res.Err = p.ModifyClient(change.Desired)
+		case CLIENT_DELETE:
+			_, res.Err = p.DeleteClient(change.Id)
+		default:
+			res.Err = fmt.Errorf("Unknown action %v", change.Action)
+		}
+		if res.Err != nil {
+			failed++
+		}
+		results = append(results, res)
+	}
+	if failed > 0 {
+		err = fmt.Errorf("%v of %v client changes failed", failed, len(plan.Changes))
+	}
+	return
+}
+
+//Plans and applies the changes needed to reach the desired state
+func (p Pipeline) ReconcileClients(cfg ClientsConfig) (plan ClientPlan, results []ClientChangeResult, err error) {
+	if plan, err = p.PlanClients(cfg); err != nil {
+		return
+	}
+	results, err = p.ApplyClients(plan)
+	return
+}
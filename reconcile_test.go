@@ -0,0 +1,98 @@
+package pipeline
+
+import (
+	"testing"
+
+	"github.com/capitancambio/restclient"
+)
+
+const clientsConfigJson = `{
+	"clients": [
+		{"id": "app", "role": "CLIENTAPP", "contact": "new@example.org"},
+		{"id": "same", "role": "ADMIN", "priority": "high"},
+		{"id": "fresh", "role": "CLIENTAPP", "priority": "low"}
+	],
+	"prune": true,
+	"protected": ["admin"]
+}`
+
+const serverClientsXml = `<clients xmlns="http://www.daisy.org/ns/pipeline/data">
+    <client id="app" role="CLIENTAPP" contact="old@example.org" priority="medium"/>
+    <client id="same" role="ADMIN" priority="high"/>
+    <client id="gone" role="CLIENTAPP"/>
+    <client id="admin" role="ADMIN"/>
+</clients>`
+
+func TestParseClientsConfig(t *testing.T) {
+	cfg, err := ParseClientsConfig([]byte(clientsConfigJson))
+	if err != nil {
+		t.Fatalf("Unexpected error %v", err)
+	}
+	if len(cfg.Clients) != 3 || !cfg.Prune || cfg.Clients[1].Priority != PRIORITY_HIGH {
+		t.Errorf("Wrong config %+v", cfg)
+	}
+	if _, err := ParseClientsConfig([]byte(`{"clients": [{"id": "a", "role": "ADMIN"}, {"id": "a", "role": "ADMIN"}]}`)); err == nil {
+		t.Errorf("Duplicated ids not detected")
+	}
+	if _, err := ParseClientsConfig([]byte(`{"clients": [{"id": "a", "role": "ROOT"}]}`)); err == nil {
+		t.Errorf("Invalid role not detected")
+	}
+}
+
+func TestReconcileClients(t *testing.T) {
+	cfg, _ := ParseClientsConfig([]byte(clientsConfigJson))
+	recorder := &mockRecorder{}
+	pipeline := createPipeline(routedClientMock(map[string]mockHandler{
+		"GET admin/clients":     xmlRoute(serverClientsXml, 200),
+		"GET admin/clients/app": xmlRoute(`<client xmlns="http://www.daisy.org/ns/pipeline/data" id="app" role="CLIENTAPP" secret="s3cret"/>`, 200),
+		"PUT admin/clients/app": xmlRoute(`<client xmlns="http://www.daisy.org/ns/pipeline/data" id="app" role="CLIENTAPP"/>`, 200),
+		"POST admin/clients": func(rr *restclient.RequestResponse) (string, int) {
+			return `<client xmlns="http://www.daisy.org/ns/pipeline/data" id="fresh" role="CLIENTAPP"/>`, 201
+		},
+		"DELETE admin/clients/gone": xmlRoute("", 204),
+	}, recorder))
+	plan, results, err := pipeline.ReconcileClients(cfg)
+	if err != nil {
+		t.Fatalf("Unexpected error %v", err)
+	}
+	exp := "update app (contact)\ncreate fresh\ndelete gone"
+	if plan.String() != exp || !plan.Drift() {
+		t.Errorf(T_STRING, "plan", exp, plan.String())
+	}
+	if len(results) != 3 || results[1].Client.Secret == "" {
+		t.Errorf("Wrong results %+v", results)
+	}
+	for _, call := range []string{"PUT admin/clients/app", "POST admin/clients", "DELETE admin/clients/gone"} {
+		if recorder.count(call) != 1 {
+			t.Errorf("Missing call %v in %v", call, recorder.calls)
+		}
+	}
+	if recorder.count("DELETE admin/clients/admin") != 0 {
+		t.Errorf("Protected client deleted")
+	}
+}
+
+func TestApplyClientsErrors(t *testing.T) {
+	pipeline := createPipeline(routedClientMock(map[string]mockHandler{}, &mockRecorder{}))
+	plan := ClientPlan{Changes: []ClientChange{{Action: CLIENT_DELETE, Id: "x"}, {Action: "rename", Id: "y"}}}
+	results, err := pipeline.ApplyClients(plan)
+	if err == nil || len(results) != 2 || results[0].Err == nil || results[1].Err == nil {
+		t.Errorf("Wrong results %+v %v", results, err)
+	}
+}
+
+func TestApplyClientsUnknownSecret(t *testing.T) {
+	recorder := &mockRecorder{}
+	pipeline := createPipeline(routedClientMock(map[string]mockHandler{
+		"GET admin/clients/app": xmlRoute(`<client xmlns="http://www.daisy.org/ns/pipeline/data" id="app" role="CLIENTAPP"/>`, 200),
+		"PUT admin/clients/app": xmlRoute(`<client xmlns="http://www.daisy.org/ns/pipeline/data" id="app" role="CLIENTAPP"/>`, 200),
+	}, recorder))
+	plan := ClientPlan{Changes: []ClientChange{{Action: CLIENT_UPDATE, Id: "app", Desired: Client{Id: "app", Role: ROLE_ADMIN}}}}
+	results, err := pipeline.ApplyClients(plan)
+	if err == nil || len(results) != 1 || results[0].Err != ErrSecretUnknown {
+		t.Errorf("Wrong results %+v %v", results, err)
+	}
+	if n := recorder.count("PUT"); n != 0 {
+		t.Errorf(T_STRING, "updates", 0, n)
+	}
+}
@@ -0,0 +1,208 @@
+package pipeline
+
+import (
+	"errors"
+	"sort"
+	"time"
+)
+
+//Why a job is selected for deletion
+const (
+	RETAIN_AGE    = "age"    //older than the maximum age
+	RETAIN_SIZE   = "size"   //needed to fit in the size budget
+	RETAIN_FILTER = "filter" //matches the status and batch filters
+)
+
+//Error messages
+var ErrEmptyPolicy = errors.New("Retention policy has no criteria, refusing to delete every job")
+
+//Total size in bytes used by the job
+func (s JobSize) Total() int {
+	return s.Output + s.Context + s.Log
+}
+
+//Which jobs to remove. Only finished jobs are ever removed, those which are
+//idle or running are kept no matter the policy
+type RetentionPolicy struct {
+	MaxAge   time.Duration //Remove jobs older than this
+	MaxTotal int           //Remove the oldest jobs until the total size in bytes fits this budget
+	Statuses []string      //Only consider jobs in these statuses, all final statuses if empty
+	BatchIds []string      //Only consider jobs in these batches, all jobs if empty
+}
+
+func (r RetentionPolicy) eligible(job Job) bool {
+	if !Finished(job.Status) {
+		return false
+	}
+	if len(r.Statuses) > 0 && !containsString(r.Statuses, job.Status) {
+		return false
+	}
+	if len(r.BatchIds) > 0 && !containsString(r.BatchIds, job.BatchId) {
+		return false
+	}
+	return true
+}
+
+func containsString(values []string, value string) bool {
+	for _, v := range values {
+		if v == value {
+			return true
+		}
+	}
+	return false
+}
+
+//Job selected for deletion
+type RetentionCandidate struct {
+	Job     Job
+	Size    JobSize
+	Created time.Time //Zero if unknown
+	Reason  string
+}
+
+//Result of applying the policy
+type RetentionReport struct {
+	DryRun      bool                 //Nothing was deleted
+	Candidates  []RetentionCandidate //Jobs selected for deletion
+	Deleted     []string             //Ids of the jobs actually deleted
+	Errors      map[string]error     //Deletion errors by job id
+	TotalBefore int                  //Total size in bytes before the deletion
+	Freed       int                  //Bytes freed (or that would be freed in a dry run)
+	Unknown     map[string]error     //Jobs whose creation time couldn't be found, the age and size rules never select them
+}
+
+//Applies a retention policy to the jobs of the server. The age and size
+//rules need the creation time of the jobs, which the server doesn't
+//provide. By default it's taken from the first entry of the job log, so
+//every Plan downloads the whole log of every eligible job. On busy servers
+//set JobTime to a cheaper source, e.g. a local record of the submissions
+type RetentionManager struct {
+	Policy   RetentionPolicy
+	JobTime  func(Job) (time.Time, error) //Creation time of a job, taken from the first log entry by default
+	Location *time.Location               //Time zone the server writes the log times in, UTC if nil
+	Now      func() time.Time
+	pipeline Pipeline
+}
+
+//Creates a new manager for the given policy
+func NewRetentionManager(p Pipeline, policy RetentionPolicy) *RetentionManager {
+	m := &RetentionManager{
+		Policy:   policy,
+		Now:      time.Now,
+		pipeline: p,
+	}
+	m.JobTime = m.logStartTime
+	return m
+}
+
+//The log times carry no zone, they are read in the location of the manager
+func (m *RetentionManager) logStartTime(job Job) (time.Time, error) {
+	t, err := m.pipeline.JobStartTime(job)
+	if err != nil || m.Location == nil {
+		return t, err
+	}
+	return time.Date(t.Year(), t.Month(), t.Day(), t.Hour(), t.Minute(), t.Second(), t.Nanosecond(), m.Location), nil
+}
+
+//Returns the time of the first entry of the job log. The log has no time
+//zone so the time is read as UTC, and the whole log is downloaded
+func (p Pipeline) JobStartTime(job Job) (time.Time, error) {
+	records, err := p.LogRecords(job.Id, func(r LogRecord) bool { return !r.Time.IsZero() })
+	if err != nil {
+		return time.Time{}, err
+	}
+	if len(records) == 0 {
+		return time.Time{}, errors.New("Job " + job.Id + " log has no entries")
+	}
+	return records[0].Time, nil
+}
+
+//Computes what the policy would delete without deleting anything
+func (m *RetentionManager) Plan() (report RetentionReport, err error) {
+	policy := m.Policy
+	if policy.MaxAge <= 0 && policy.MaxTotal <= 0 && len(policy.Statuses) == 0 && len(policy.BatchIds) == 0 {
+		return report, ErrEmptyPolicy
+	}
+	report.DryRun = true
+	jobs, err := m.pipeline.Jobs()
+	if err != nil {
+		return
+	}
+	sizes, err := m.pipeline.Sizes()
+	if err != nil {
+		return
+	}
+	sizeById := make(map[string]JobSize)
+	for _, size := range sizes.JobSizes {
+		sizeById[size.Id] = size
+		report.TotalBefore += size.Total()
+	}
+	var kept []RetentionCandidate
+	needTimes := policy.MaxAge > 0 || policy.MaxTotal > 0
+	for _, job := range jobs.Jobs {
+		if !policy.eligible(job) {
+			continue
+		}
+		cand := RetentionCandidate{Job: job, Size: sizeById[job.Id]}
+		if needTimes {
+			var err error
+			if cand.Created, err = m.JobTime(job); err == nil && cand.Created.IsZero() {
+				err = errors.New("Job " + job.Id + " has no creation time")
+			}
+			//an unknown time doesn't make the job old, keep it
+			if err != nil {
+				if report.Unknown == nil {
+					report.Unknown = make(map[string]error)
+				}
+				report.Unknown[job.Id] = err
+				continue
+			}
+		}
+		switch {
+		case policy.MaxAge > 0 && m.Now().Sub(cand.Created) > policy.MaxAge:
+			cand.Reason = RETAIN_AGE
+		case policy.MaxAge <= 0 && policy.MaxTotal <= 0:
+			cand.Reason = RETAIN_FILTER
+		}
+		if cand.Reason != "" {
+			report.Candidates = append(report.Candidates, cand)
+			report.Freed += cand.Size.Total()
+		} else {
+			kept = append(kept, cand)
+		}
+	}
+	if policy.MaxTotal > 0 {
+		sort.SliceStable(kept, func(i, j int) bool {
+			return kept[i].Created.Before(kept[j].Created)
+		})
+		for _, cand := range kept {
+			if report.TotalBefore-report.Freed <= policy.MaxTotal {
+				break
+			}
+			cand.Reason = RETAIN_SIZE
+			report.Candidates = append(report.Candidates, cand)
+			report.Freed += cand.Size.Total()
+		}
+	}
+	return
+}
+
+//Deletes the jobs selected by the policy, a dry run only reports them
+func (m *RetentionManager) Apply(dryRun bool) (report RetentionReport, err error) {
+	report, err = m.Plan()
+	if err != nil || dryRun {
+		return
+	}
+	report.DryRun = false
+	report.Errors = make(map[string]error)
+	report.Freed = 0
+	for _, cand := range report.Candidates {
+		if _, err := m.pipeline.DeleteJob(cand.Job.Id); err != nil {
+			report.Errors[cand.Job.Id] = err
+			continue
+		}
+		report.Deleted = append(report.Deleted, cand.Job.Id)
+		report.Freed += cand.Size.Total()
+	}
+	return
+}
@@ -0,0 +1,125 @@
+package pipeline
+
+import (
+	"testing"
+	"time"
+
+	"github.com/capitancambio/restclient"
+)
+
+const sizesXml = `<jobSizes xmlns="http://www.daisy.org/ns/pipeline/data" total="1000">
+    <jobSize id="job-id-01" output="100" context="50" log="50"/>
+    <jobSize id="job-id-02" output="300" context="50" log="50"/>
+    <jobSize id="job-id-03" output="200" context="0" log="0"/>
+    <jobSize id="job-id-04" output="200" context="0" log="0"/>
+</jobSizes>`
+
+func retentionPipeline(recorder *mockRecorder) Pipeline {
+	return createPipeline(routedClientMock(map[string]mockHandler{
+		"GET jobs":        xmlRoute(jobsXml, 200),
+		"GET admin/sizes": xmlRoute(sizesXml, 200),
+		"GET jobs/": func(rr *restclient.RequestResponse) (string, int) {
+			if rr.Url == "base/jobs/job-id-01/log" {
+				return "2013-05-07 15:51:42,123 [INFO ] org.daisy.Job - Starting job\n", 200
+			}
+			return "2014-05-07 15:51:42,123 [INFO ] org.daisy.Job - Starting job\n", 200
+		},
+		"DELETE jobs/": xmlRoute("", 204),
+	}, recorder))
+}
+
+func TestRetentionByAge(t *testing.T) {
+	recorder := &mockRecorder{}
+	manager := NewRetentionManager(retentionPipeline(recorder), RetentionPolicy{MaxAge: 24 * time.Hour})
+	manager.Now = func() time.Time { return time.Date(2014, 5, 7, 16, 0, 0, 0, time.UTC) }
+	report, err := manager.Apply(true)
+	if err != nil {
+		t.Fatalf("Unexpected error %v", err)
+	}
+	if len(report.Candidates) != 1 || report.Candidates[0].Job.Id != "job-id-01" || report.Candidates[0].Reason != RETAIN_AGE {
+		t.Errorf("Wrong candidates %+v", report.Candidates)
+	}
+	if report.Freed != 200 || report.TotalBefore != 1000 || !report.DryRun {
+		t.Errorf("Wrong report %+v", report)
+	}
+	if recorder.count("DELETE") != 0 {
+		t.Errorf("Dry run deleted jobs")
+	}
+}
+
+func TestRetentionBySize(t *testing.T) {
+	recorder := &mockRecorder{}
+	manager := NewRetentionManager(retentionPipeline(recorder), RetentionPolicy{MaxTotal: 800})
+	report, err := manager.Apply(false)
+	if err != nil {
+		t.Fatalf("Unexpected error %v", err)
+	}
+	//job 1 is the oldest and frees enough, idle and running jobs are never removed
+	if len(report.Deleted) != 1 || report.Deleted[0] != "job-id-01" {
+		t.Errorf("Wrong deleted jobs %+v", report.Deleted)
+	}
+	if recorder.count("DELETE jobs/job-id-01") != 1 {
+		t.Errorf("Job not deleted %v", recorder.calls)
+	}
+	manager.Policy.MaxTotal = 100
+	report, _ = manager.Apply(true)
+	if len(report.Candidates) != 2 || report.Freed != 600 {
+		t.Errorf("Wrong report %+v", report)
+	}
+}
+
+func TestRetentionByStatus(t *testing.T) {
+	manager := NewRetentionManager(retentionPipeline(&mockRecorder{}), RetentionPolicy{Statuses: []string{JOB_ERROR, JOB_RUNNING}})
+	report, err := manager.Plan()
+	if err != nil {
+		t.Fatalf("Unexpected error %v", err)
+	}
+	if len(report.Candidates) != 1 || report.Candidates[0].Job.Id != "job-id-02" || report.Candidates[0].Reason != RETAIN_FILTER {
+		t.Errorf("Wrong candidates %+v", report.Candidates)
+	}
+	manager.Policy = RetentionPolicy{}
+	if _, err := manager.Plan(); err != ErrEmptyPolicy {
+		t.Errorf(T_STRING, "error", ErrEmptyPolicy, err)
+	}
+}
+
+func TestRetentionUnknownTime(t *testing.T) {
+	recorder := &mockRecorder{}
+	pipeline := createPipeline(routedClientMock(map[string]mockHandler{
+		"GET jobs":               xmlRoute(jobsXml, 200),
+		"GET admin/sizes":        xmlRoute(sizesXml, 200),
+		"GET jobs/job-id-01/log": xmlRoute("", 404),
+		"GET jobs/":              xmlRoute("no timestamps here\n", 200),
+		"DELETE jobs/":           xmlRoute("", 204),
+	}, recorder))
+	for _, policy := range []RetentionPolicy{{MaxAge: time.Hour}, {MaxTotal: 1}} {
+		report, err := NewRetentionManager(pipeline, policy).Apply(false)
+		if err != nil {
+			t.Fatalf("Unexpected error %v", err)
+		}
+		if len(report.Candidates) != 0 || len(report.Deleted) != 0 {
+			t.Errorf("Jobs without time selected %+v", report.Candidates)
+		}
+		if report.Unknown["job-id-01"] == nil || report.Unknown["job-id-02"] == nil {
+			t.Errorf("Unknown times not reported %v", report.Unknown)
+		}
+	}
+	if n := recorder.count("DELETE"); n != 0 {
+		t.Errorf(T_STRING, "deletions", 0, n)
+	}
+}
+
+func TestRetentionLocation(t *testing.T) {
+	manager := NewRetentionManager(retentionPipeline(&mockRecorder{}), RetentionPolicy{MaxAge: 30 * time.Minute})
+	//the server writes its logs two hours ahead of UTC
+	manager.Location = time.FixedZone("server", 2*3600)
+	manager.Now = func() time.Time { return time.Date(2014, 5, 7, 16, 0, 0, 0, time.UTC) }
+	report, err := manager.Plan()
+	if err != nil {
+		t.Fatalf("Unexpected error %v", err)
+	}
+	//job 2 started at 13:51:42 UTC, not 8 minutes ago
+	if len(report.Candidates) != 2 || report.Candidates[1].Job.Id != "job-id-02" {
+		t.Errorf("Wrong candidates %+v", report.Candidates)
+	}
+}
@@ -0,0 +1,85 @@
+package pipeline
+
+import (
+	"net/http"
+	"time"
+
+	"github.com/capitancambio/restclient"
+)
+
+//Default values of the retry policy
+const (
+	DEFAULT_RETRY_ATTEMPTS    = 3
+	DEFAULT_RETRY_BACKOFF     = 500 * time.Millisecond
+	DEFAULT_RETRY_MAX_BACKOFF = 10 * time.Second
+)
+
+//Retries failed requests to the idempotent api entries, waiting twice as
+//long after every attempt. Zero fields take the default values
+type RetryPolicy struct {
+	Attempts   int                              //Tries, the first one included
+	Backoff    time.Duration                    //Wait before the first retry
+	MaxBackoff time.Duration                    //Longest wait between tries
+	Retryable  func(status int, err error) bool //Whether to try again, connection errors and 502, 503 and 504 by default
+}
+
+//Connection errors and gateway or availability problems
+func defaultRetryable(status int, err error) bool {
+	if err == nil {
+		return false
+	}
+	if err != restclient.UnexpectedStatus {
+		return status == 0
+	}
+	return status == 502 || status == 503 || status == 504
+}
+
+//Middleware doing the retries. It goes before the authenticator so every
+//try is signed again
+func (r RetryPolicy) Middleware() Middleware {
+	if r.Attempts <= 0 {
+		r.Attempts = DEFAULT_RETRY_ATTEMPTS
+	}
+	if r.Backoff <= 0 {
+		r.Backoff = DEFAULT_RETRY_BACKOFF
+	}
+	if r.MaxBackoff <= 0 {
+		r.MaxBackoff = DEFAULT_RETRY_MAX_BACKOFF
+	}
+	if r.Retryable == nil {
+		r.Retryable = defaultRetryable
+	}
+	return func(next Handler) Handler {
+		return func(req *Request) (status int, err error) {
+			if !idempotent(req.Entry) {
+				return next(req)
+			}
+			url, method := req.Url, req.Method
+			var header http.Header
+			if req.Header != nil {
+				header = req.Header.Clone()
+			}
+			wait := r.Backoff
+			for attempt := 1; ; attempt++ {
+				status, err = next(req)
+				if attempt >= r.Attempts || !r.Retryable(status, err) {
+					return
+				}
+				select {
+				case <-req.Context.Done():
+					return
+				case <-time.After(wait):
+				}
+				if wait *= 2; wait > r.MaxBackoff {
+					wait = r.MaxBackoff
+				}
+				//undo what the inner middlewares did, e.g. signing
+				req.Url, req.Method = url, method
+				if header != nil {
+					restored := header.Clone()
+					req.Header = &restored
+				}
+			}
+		}
+	}
+}
@@ -0,0 +1,109 @@
+package pipeline
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/capitancambio/restclient"
+)
+
+func TestRetryIdempotent(t *testing.T) {
+	calls := 0
+	var urls []string
+	routes := map[string]mockHandler{
+		"GET alive": func(rr *restclient.RequestResponse) (string, int) {
+			calls++
+			urls = append(urls, rr.Url)
+			if calls < 3 {
+				return "", 503
+			}
+			return aliveXml, 200
+		},
+	}
+	pipeline := createPipeline(routedClientMock(routes, &mockRecorder{}))
+	pipeline.SetCredentials("cli", "shhh")
+	pipeline.Use(RetryPolicy{Backoff: time.Millisecond}.Middleware())
+	alive, err := pipeline.Alive()
+	if err != nil {
+		t.Fatalf("Unexpected error %v", err)
+	}
+	if calls != 3 || alive.Version != "1.6" {
+		t.Errorf("Wrong retries %v %v", calls, alive.Version)
+	}
+	for _, url := range urls {
+		if strings.Count(url, "sign=") != 1 {
+			t.Errorf("Retry signed more than once %v", url)
+		}
+	}
+}
+
+func TestRetryGivesUp(t *testing.T) {
+	recorder := &mockRecorder{}
+	pipeline := createPipeline(routedClientMock(map[string]mockHandler{
+		"GET alive": xmlRoute("", 503),
+		"GET jobs":  xmlRoute("", 404),
+	}, recorder))
+	pipeline.Use(RetryPolicy{Attempts: 2, Backoff: time.Millisecond}.Middleware())
+	if _, err := pipeline.Alive(); err == nil {
+		t.Errorf("Expected error")
+	}
+	if n := recorder.count("GET alive"); n != 2 {
+		t.Errorf(T_STRING, "attempts", 2, n)
+	}
+	//not retryable
+	pipeline.Jobs()
+	if n := recorder.count("GET jobs"); n != 1 {
+		t.Errorf(T_STRING, "attempts", 1, n)
+	}
+}
+
+func TestRetrySkipsNonIdempotent(t *testing.T) {
+	recorder := &mockRecorder{}
+	pipeline := createPipeline(routedClientMock(map[string]mockHandler{
+		"POST jobs": xmlRoute("", 503),
+	}, recorder))
+	pipeline.Use(RetryPolicy{Backoff: time.Millisecond}.Middleware())
+	pipeline.JobRequest(JobRequest{}, nil)
+	if n := recorder.count("POST jobs"); n != 1 {
+		t.Errorf(T_STRING, "attempts", 1, n)
+	}
+}
+
+func TestRetryContext(t *testing.T) {
+	recorder := &mockRecorder{}
+	pipeline := createPipeline(routedClientMock(map[string]mockHandler{
+		"GET alive": xmlRoute("", 503),
+	}, recorder))
+	pipeline.Use(RetryPolicy{Attempts: 10, Backoff: time.Hour}.Middleware())
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Millisecond)
+	defer cancel()
+	if _, err := pipeline.WithContext(ctx).Alive(); err == nil {
+		t.Errorf("Expected error")
+	}
+	if n := recorder.count("GET alive"); n != 1 {
+		t.Errorf(T_STRING, "attempts", 1, n)
+	}
+}
+
+func TestDefaultRetryable(t *testing.T) {
+	tests := []struct {
+		status int
+		err    error
+		exp    bool
+	}{
+		{200, nil, false},
+		{0, errors.New("connection refused"), true},
+		{503, restclient.UnexpectedStatus, true},
+		{404, restclient.UnexpectedStatus, false},
+		{500, restclient.UnexpectedStatus, false},
+		{200, errors.New("bad xml"), false},
+	}
+	for _, test := range tests {
+		if res := defaultRetryable(test.status, test.err); res != test.exp {
+			t.Errorf(T_STRING, test, test.exp, res)
+		}
+	}
+}
@@ -0,0 +1,72 @@
+package pipeline
+
+import (
+	"errors"
+	"fmt"
+)
+
+//Outcome of a secret rotation
+type SecretRotation struct {
+	ClientId   string
+	OldSecret  string
+	NewSecret  string //Secret in use if the rotation succeeded
+	Verified   bool   //The new credentials were accepted by the server
+	RolledBack bool   //The old secret was restored after a failed verification
+}
+
+//Rotates the secrets of the web service clients using an admin pipeline
+type SecretRotator struct {
+	Length   int                    //Length of the new secrets
+	Verify   func(p Pipeline) error //Authenticated call done with the new credentials, lists the jobs by default
+	pipeline Pipeline
+}
+
+//Creates a new rotator, admin must have admin credentials
+func NewSecretRotator(admin Pipeline) *SecretRotator {
+	return &SecretRotator{
+		Length: DEFAULT_SECRET_LENGTH,
+		Verify: func(p Pipeline) error {
+			_, err := p.Jobs()
+			return err
+		},
+		pipeline: admin,
+	}
+}
+
+//Sets a new secret for the client and checks it works through a second
+//pipeline using the new credentials, restoring the old secret if it doesn't.
+//oldSecret is fetched from the server when empty
+func (r *SecretRotator) Rotate(clientId, oldSecret string) (rotation SecretRotation, err error) {
+	rotation.ClientId = clientId
+	if oldSecret == "" {
+		var current Client
+		if current, err = r.pipeline.Client(clientId); err != nil {
+			return
+		}
+		oldSecret = current.Secret
+	}
+	if oldSecret == "" {
+		return rotation, errors.New("The old secret of " + clientId + " is unknown, it couldn't be restored")
+	}
+	rotation.OldSecret = oldSecret
+	newSecret, err := GenerateSecret(r.Length)
+	if err != nil {
+		return
+	}
+	if _, err = r.pipeline.ModifyClient(Client{Id: clientId, Secret: newSecret}); err != nil {
+		return
+	}
+	verifier := r.pipeline
+	verifier.SetCredentials(clientId, newSecret)
+	verr := r.Verify(verifier)
+	if verr == nil {
+		rotation.NewSecret = newSecret
+		rotation.Verified = true
+		return
+	}
+	if _, err = r.pipeline.ModifyClient(Client{Id: clientId, Secret: oldSecret}); err != nil {
+		return rotation, fmt.Errorf("Verification of the new secret failed (%v) and the old one couldn't be restored: %v", verr, err)
+	}
+	rotation.RolledBack = true
+	return rotation, fmt.Errorf("Verification of the new secret failed, the old one was restored: %v", verr)
+}
@@ -0,0 +1,75 @@
+package pipeline
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+	"testing"
+
+	"github.com/capitancambio/restclient"
+)
+
+//Server side of a client whose secret can be changed
+type mockAdmin struct {
+	secret   string
+	accepted func(secret string) bool
+}
+
+func (m *mockAdmin) pipeline(recorder *mockRecorder) Pipeline {
+	client := func() string {
+		return fmt.Sprintf(`<client xmlns="http://www.daisy.org/ns/pipeline/data" id="app" role="CLIENTAPP" secret="%v"/>`, m.secret)
+	}
+	return createPipeline(routedClientMock(map[string]mockHandler{
+		"GET admin/clients/app": func(*restclient.RequestResponse) (string, int) {
+			return client(), 200
+		},
+		"PUT admin/clients/app": func(rr *restclient.RequestResponse) (string, int) {
+			m.secret = rr.Data.(*Client).Secret
+			return client(), 200
+		},
+		"GET jobs": func(rr *restclient.RequestResponse) (string, int) {
+			if strings.Contains(rr.Url, "authid=app") && m.accepted(m.secret) {
+				return jobsXml, 200
+			}
+			return "", 401
+		},
+	}, recorder))
+}
+
+func TestRotateSecret(t *testing.T) {
+	admin := &mockAdmin{secret: "old", accepted: func(string) bool { return true }}
+	rotator := NewSecretRotator(admin.pipeline(&mockRecorder{}))
+	rotation, err := rotator.Rotate("app", "")
+	if err != nil {
+		t.Fatalf("Unexpected error %v", err)
+	}
+	if !rotation.Verified || rotation.OldSecret != "old" || rotation.NewSecret != admin.secret || len(admin.secret) != DEFAULT_SECRET_LENGTH {
+		t.Errorf("Wrong rotation %+v", rotation)
+	}
+}
+
+func TestRotateSecretRollback(t *testing.T) {
+	admin := &mockAdmin{secret: "old", accepted: func(s string) bool { return s == "old" }}
+	recorder := &mockRecorder{}
+	rotator := NewSecretRotator(admin.pipeline(recorder))
+	rotation, err := rotator.Rotate("app", "given")
+	if err == nil {
+		t.Errorf("Expected error not thrown")
+	}
+	if rotation.Verified || !rotation.RolledBack || admin.secret != "given" {
+		t.Errorf("Wrong rotation %+v, secret %v", rotation, admin.secret)
+	}
+	if recorder.count("PUT admin/clients/app") != 2 {
+		t.Errorf("Wrong calls %v", recorder.calls)
+	}
+}
+
+func TestRotateSecretCustomVerify(t *testing.T) {
+	admin := &mockAdmin{secret: "old", accepted: func(string) bool { return true }}
+	rotator := NewSecretRotator(admin.pipeline(&mockRecorder{}))
+	rotator.Verify = func(Pipeline) error { return errors.New("nope") }
+	rotation, err := rotator.Rotate("app", "")
+	if err == nil || !rotation.RolledBack || admin.secret != "old" {
+		t.Errorf("Wrong rotation %+v, secret %v", rotation, admin.secret)
+	}
+}
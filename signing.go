@@ -0,0 +1,108 @@
+package pipeline
+
+import (
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha1"
+	"encoding/base64"
+	"fmt"
+	"math/big"
+	"net/url"
+	"time"
+)
+
+//Format of the time parameter, always in UTC
+const SIGN_TIME_FORMAT = "2006-01-02T15:04:05Z"
+
+//Digits of the nonces
+const NONCE_LENGTH = 30
+
+var maxNonce = new(big.Int).Exp(big.NewInt(10), big.NewInt(NONCE_LENGTH), nil)
+
+//Signs the urls the way the framework verifies them: the authid, time and
+//nonce parameters are appended to the url, the result is signed with
+//HMAC-SHA1 using the client secret and the base64 signature is appended as
+//the last parameter, sign. The server checks the signature over the url up
+//to "&sign=", that the time is recent and that the nonce isn't reused
+type Signer struct {
+	ClientKey    string
+	ClientSecret string
+	Provider     CredentialProvider     //Consulted on every request instead of ClientKey and ClientSecret if set
+	Skew         time.Duration          //Added to the local clock to match the server one
+	Now          func() time.Time       //Clock, time.Now if nil
+	Nonce        func() (string, error) //Nonce generator, crypto/rand based if nil
+}
+
+//Generates a random nonce of NONCE_LENGTH digits using crypto/rand
+func NewNonce() (string, error) {
+	n, err := rand.Int(rand.Reader, maxNonce)
+	if err != nil {
+		return "", fmt.Errorf("Error generating nonce: %v", err)
+	}
+	return fmt.Sprintf("%0*d", NONCE_LENGTH, n), nil
+}
+
+//Returns the url with the authentication parameters and the signature
+func (s Signer) Sign(rawUrl string) (string, error) {
+	u, err := url.Parse(rawUrl)
+	if err != nil {
+		return "", fmt.Errorf("Error signing url: %v", err)
+	}
+	creds := Credentials{s.ClientKey, s.ClientSecret}
+	if s.Provider != nil {
+		if creds, err = s.Provider.Credentials(); err != nil {
+			return "", err
+		}
+	}
+	now := time.Now
+	if s.Now != nil {
+		now = s.Now
+	}
+	nonce := NewNonce
+	if s.Nonce != nil {
+		nonce = s.Nonce
+	}
+	n, err := nonce()
+	if err != nil {
+		return "", err
+	}
+	timestamp := now().Add(s.Skew).UTC().Format(SIGN_TIME_FORMAT)
+	//the time only has characters allowed in queries, the server expects
+	//it as is
+	authPart := "authid=" + url.QueryEscape(creds.ClientKey) + "&time=" + timestamp + "&nonce=" + url.QueryEscape(n)
+	if u.RawQuery != "" {
+		u.RawQuery += "&" + authPart
+	} else {
+		u.RawQuery = authPart
+	}
+	u.Fragment = ""
+	//sign the url as it will be sent
+	signed := u.String()
+	return signed + "&sign=" + url.QueryEscape(signature(signed, creds.ClientSecret)), nil
+}
+
+//Middleware signing the requests
+func (s Signer) Middleware() Middleware {
+	return func(next Handler) Handler {
+		return func(req *Request) (int, error) {
+			signed, err := s.Sign(req.Url)
+			if err != nil {
+				return 0, err
+			}
+			req.Url = signed
+			return next(req)
+		}
+	}
+}
+
+//Signs the requests with the client key and secret
+func Authenticate(clientKey, clientSecret string) Middleware {
+	return Signer{ClientKey: clientKey, ClientSecret: clientSecret}.Middleware()
+}
+
+//Computes the base64 encoded HMAC-SHA1 of the uri using the secret
+func signature(uri, cSecret string) string {
+	hasher := hmac.New(sha1.New, []byte(cSecret))
+	hasher.Write([]byte(uri))
+	return base64.StdEncoding.EncodeToString(hasher.Sum(nil))
+}
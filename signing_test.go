@@ -0,0 +1,156 @@
+package pipeline
+
+import (
+	"errors"
+	"net/url"
+	"strings"
+	"testing"
+	"time"
+)
+
+//Replica of the verification done by the framework web service: the hash is
+//computed over the url as received up to "&sign=", the time must be within
+//maxAge of the server clock and nonces can't be reused
+func serverVerify(requestUri, secret string, now time.Time, maxAge time.Duration, nonces map[string]bool) error {
+	idx := strings.Index(requestUri, "&sign=")
+	if idx < 0 {
+		return errors.New("not signed")
+	}
+	u, err := url.Parse(requestUri)
+	if err != nil {
+		return err
+	}
+	query := u.Query()
+	if signature(requestUri[:idx], secret) != query.Get("sign") {
+		return errors.New("wrong signature")
+	}
+	timestamp, err := time.ParseInLocation(SIGN_TIME_FORMAT, query.Get("time"), time.UTC)
+	if err != nil {
+		return err
+	}
+	if diff := now.Sub(timestamp); diff > maxAge || diff < -maxAge {
+		return errors.New("expired")
+	}
+	if nonces[query.Get("nonce")] {
+		return errors.New("nonce reused")
+	}
+	nonces[query.Get("nonce")] = true
+	return nil
+}
+
+func fixedClock(t time.Time) func() time.Time {
+	return func() time.Time { return t }
+}
+
+func fixedNonce(n string) func() (string, error) {
+	return func() (string, error) { return n, nil }
+}
+
+func TestSignVectors(t *testing.T) {
+	//signatures computed independently with HMAC-SHA1
+	at := time.Date(2013, 4, 5, 12, 0, 0, 0, time.UTC)
+	tests := []struct {
+		signer Signer
+		url    string
+		exp    string
+	}{
+		{
+			Signer{ClientKey: "cli", ClientSecret: "shhh", Now: fixedClock(at), Nonce: fixedNonce("000000000000000000000000000042")},
+			"http://localhost:8181/ws/alive",
+			"http://localhost:8181/ws/alive?authid=cli&time=2013-04-05T12:00:00Z&nonce=000000000000000000000000000042&sign=M0r3vWWoMtlzUM%2BSfnRWPF0PugI%3D",
+		},
+		{
+			Signer{ClientKey: "me@example.org", ClientSecret: "s3cr3t", Now: fixedClock(at), Nonce: fixedNonce("123456789012345678901234567890")},
+			"http://localhost:8181/ws/jobs/abc?msgSeq=3",
+			"http://localhost:8181/ws/jobs/abc?msgSeq=3&authid=me%40example.org&time=2013-04-05T12:00:00Z&nonce=123456789012345678901234567890&sign=F7Xv2FRYLx33%2FdayrPRKQTzdvaE%3D",
+		},
+	}
+	for _, test := range tests {
+		res, err := test.signer.Sign(test.url)
+		if err != nil {
+			t.Errorf("Unexpected error %v", err)
+		}
+		if res != test.exp {
+			t.Errorf(T_STRING, test.url, test.exp, res)
+		}
+	}
+}
+
+func TestSignUtc(t *testing.T) {
+	zone := time.FixedZone("CEST", 2*60*60)
+	signer := Signer{ClientKey: "cli", ClientSecret: "shhh", Now: fixedClock(time.Date(2013, 4, 5, 14, 0, 0, 0, zone))}
+	res, _ := signer.Sign("http://localhost:8181/ws/alive")
+	if !strings.Contains(res, "time=2013-04-05T12:00:00Z") {
+		t.Errorf("Time not in UTC %v", res)
+	}
+	signer.Skew = -time.Minute
+	res, _ = signer.Sign("http://localhost:8181/ws/alive")
+	if !strings.Contains(res, "time=2013-04-05T11:59:00Z") {
+		t.Errorf("Skew not applied %v", res)
+	}
+}
+
+func TestSignVerifiedByServer(t *testing.T) {
+	nonces := make(map[string]bool)
+	signer := Signer{ClientKey: "me@example.org", ClientSecret: "shhh"}
+	for _, u := range []string{
+		"http://localhost:8181/ws/jobs",
+		"http://localhost:8181/ws/jobs/a%20b?msgSeq=3",
+		"http://localhost:8181/ws/admin/clients/me@example.org",
+	} {
+		signed, err := signer.Sign(u)
+		if err != nil {
+			t.Fatalf("Unexpected error %v", err)
+		}
+		if err := serverVerify(signed, "shhh", time.Now(), 10*time.Minute, nonces); err != nil {
+			t.Errorf("%v rejected: %v", signed, err)
+		}
+		if err := serverVerify(signed, "shhh", time.Now(), 10*time.Minute, nonces); err == nil {
+			t.Errorf("Replayed request accepted %v", signed)
+		}
+		if err := serverVerify(signed, "wrong", time.Now(), 10*time.Minute, map[string]bool{}); err == nil {
+			t.Errorf("Wrong secret accepted %v", signed)
+		}
+	}
+	//a server clock one hour behind rejects the request unless skewed
+	serverNow := time.Now().Add(-time.Hour)
+	signed, _ := signer.Sign("http://localhost:8181/ws/jobs")
+	if err := serverVerify(signed, "shhh", serverNow, 10*time.Minute, nonces); err == nil {
+		t.Errorf("Expired request accepted %v", signed)
+	}
+	signer.Skew = -time.Hour
+	signed, _ = signer.Sign("http://localhost:8181/ws/jobs")
+	if err := serverVerify(signed, "shhh", serverNow, 10*time.Minute, nonces); err != nil {
+		t.Errorf("Skewed request rejected: %v", err)
+	}
+}
+
+func TestNewNonce(t *testing.T) {
+	seen := make(map[string]bool)
+	for i := 0; i < 100; i++ {
+		nonce, err := NewNonce()
+		if err != nil {
+			t.Fatalf("Unexpected error %v", err)
+		}
+		if len(nonce) != NONCE_LENGTH || strings.Trim(nonce, "0123456789") != "" {
+			t.Errorf("Wrong nonce %v", nonce)
+		}
+		if seen[nonce] {
+			t.Errorf("Repeated nonce %v", nonce)
+		}
+		seen[nonce] = true
+	}
+}
+
+func TestSignerMiddlewareErrors(t *testing.T) {
+	fail := errors.New("no entropy")
+	cli := &MockClient{status: 204}
+	pipeline := createPipeline(func() doer { return cli })
+	pipeline.SetAuthenticator(Signer{ClientKey: "cli", Nonce: func() (string, error) { return "", fail }}.Middleware())
+	if err := pipeline.Halt("key"); err != fail {
+		t.Errorf(T_STRING, "error", fail, err)
+	}
+	if cli.request.Url != "" {
+		t.Errorf("Unsigned request sent %v", cli.request.Url)
+	}
+}
@@ -0,0 +1,132 @@
+package pipeline
+
+import (
+	"fmt"
+	"sort"
+)
+
+//Size of a job along with the job itself
+type JobUsage struct {
+	Job  Job //Job as listed by Jobs(), only the id is set if it wasn't listed
+	Size JobSize
+}
+
+//Storage used by a group of jobs
+type UsageGroup struct {
+	Key     string //Value shared by the jobs in the group
+	Jobs    int    //Number of jobs
+	Output  int    //Bytes used by the results
+	Context int    //Bytes used by the job contexts
+	Log     int    //Bytes used by the logs
+	Total   int    //Total bytes
+}
+
+//Jobs joined with their sizes
+type SizeReport struct {
+	Jobs  []JobUsage
+	Total int //Total bytes as reported by the server
+}
+
+//Joins the job list with the sizes. Sizes of jobs not in the list are kept
+//with an empty job so the totals match the server
+func NewSizeReport(jobs Jobs, sizes JobSizes) SizeReport {
+	byId := make(map[string]Job)
+	for _, job := range jobs.Jobs {
+		byId[job.Id] = job
+	}
+	report := SizeReport{Total: sizes.Total}
+	for _, size := range sizes.JobSizes {
+		job, ok := byId[size.Id]
+		if !ok {
+			job = Job{Id: size.Id}
+		}
+		report.Jobs = append(report.Jobs, JobUsage{Job: job, Size: size})
+	}
+	return report
+}
+
+//Gets the job list and the sizes and joins them
+func (p Pipeline) SizeReport() (report SizeReport, err error) {
+	jobs, err := p.Jobs()
+	if err != nil {
+		return
+	}
+	sizes, err := p.Sizes()
+	if err != nil {
+		return
+	}
+	return NewSizeReport(jobs, sizes), nil
+}
+
+//Groups the jobs by the given key, groups are sorted by total size, biggest first
+func (r SizeReport) GroupBy(key func(JobUsage) string) []UsageGroup {
+	index := make(map[string]int)
+	groups := []UsageGroup{}
+	for _, usage := range r.Jobs {
+		k := key(usage)
+		i, ok := index[k]
+		if !ok {
+			i = len(groups)
+			index[k] = i
+			groups = append(groups, UsageGroup{Key: k})
+		}
+		groups[i].Jobs++
+		groups[i].Output += usage.Size.Output
+		groups[i].Context += usage.Size.Context
+		groups[i].Log += usage.Size.Log
+		groups[i].Total += usage.Size.Total()
+	}
+	sort.SliceStable(groups, func(i, j int) bool {
+		return groups[i].Total > groups[j].Total
+	})
+	return groups
+}
+
+//Usage by script id
+func (r SizeReport) ByScript() []UsageGroup {
+	return r.GroupBy(func(u JobUsage) string { return u.Job.Script.Id })
+}
+
+//Usage by job status
+func (r SizeReport) ByStatus() []UsageGroup {
+	return r.GroupBy(func(u JobUsage) string { return u.Job.Status })
+}
+
+//Usage by batch id
+func (r SizeReport) ByBatch() []UsageGroup {
+	return r.GroupBy(func(u JobUsage) string { return u.Job.BatchId })
+}
+
+//Usage by client. The job xml doesn't say which client created the job so
+//clientOf has to tell, e.g. from the job lists of each client
+func (r SizeReport) ByClient(clientOf func(Job) string) []UsageGroup {
+	return r.GroupBy(func(u JobUsage) string { return clientOf(u.Job) })
+}
+
+//Returns the n biggest jobs, biggest first
+func (r SizeReport) Largest(n int) []JobUsage {
+	jobs := append([]JobUsage(nil), r.Jobs...)
+	sort.SliceStable(jobs, func(i, j int) bool {
+		return jobs[i].Size.Total() > jobs[j].Size.Total()
+	})
+	if n >= 0 && n < len(jobs) {
+		jobs = jobs[:n]
+	}
+	return jobs
+}
+
+var byteUnits = []string{"KiB", "MiB", "GiB", "TiB", "PiB", "EiB"}
+
+//Formats a number of bytes using binary units, e.g. 1536 is 1.5 KiB
+func FormatBytes(n int64) string {
+	if n < 1024 && n > -1024 {
+		return fmt.Sprintf("%d B", n)
+	}
+	value := float64(n)
+	unit := -1
+	for (value >= 1024 || value <= -1024) && unit < len(byteUnits)-1 {
+		value /= 1024
+		unit++
+	}
+	return fmt.Sprintf("%.1f %s", value, byteUnits[unit])
+}
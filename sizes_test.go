@@ -0,0 +1,64 @@
+package pipeline
+
+import "testing"
+
+func TestSizeReport(t *testing.T) {
+	pipeline := retentionPipeline(&mockRecorder{})
+	report, err := pipeline.SizeReport()
+	if err != nil {
+		t.Fatalf("Unexpected error %v", err)
+	}
+	if len(report.Jobs) != 4 || report.Total != 1000 {
+		t.Fatalf("Wrong report %+v", report)
+	}
+	byStatus := report.ByStatus()
+	if len(byStatus) != 4 || byStatus[0].Key != JOB_ERROR || byStatus[0].Total != 400 || byStatus[0].Output != 300 {
+		t.Errorf("Wrong usage by status %+v", byStatus)
+	}
+	byBatch := report.ByBatch()
+	if len(byBatch) != 1 || byBatch[0].Jobs != 4 || byBatch[0].Total != 1000 {
+		t.Errorf("Wrong usage by batch %+v", byBatch)
+	}
+	byClient := report.ByClient(func(job Job) string {
+		if job.Id == "job-id-01" {
+			return "other"
+		}
+		return "me"
+	})
+	if len(byClient) != 2 || byClient[0].Key != "me" || byClient[0].Total != 800 {
+		t.Errorf("Wrong usage by client %+v", byClient)
+	}
+	largest := report.Largest(2)
+	if len(largest) != 2 || largest[0].Job.Id != "job-id-02" || largest[1].Job.Id != "job-id-01" {
+		t.Errorf("Wrong largest jobs %+v", largest)
+	}
+	if len(report.Largest(10)) != 4 {
+		t.Errorf("Largest should return every job")
+	}
+}
+
+func TestSizeReportUnlistedJobs(t *testing.T) {
+	report := NewSizeReport(Jobs{}, JobSizes{JobSizes: []JobSize{{Id: "ghost", Log: 10}}, Total: 10})
+	if len(report.Jobs) != 1 || report.Jobs[0].Job.Id != "ghost" {
+		t.Errorf("Wrong report %+v", report)
+	}
+	if groups := report.ByScript(); len(groups) != 1 || groups[0].Key != "" || groups[0].Log != 10 {
+		t.Errorf("Wrong usage by script %+v", groups)
+	}
+}
+
+func TestFormatBytes(t *testing.T) {
+	cases := map[int64]string{
+		0:               "0 B",
+		1023:            "1023 B",
+		1536:            "1.5 KiB",
+		5 * 1024 * 1024: "5.0 MiB",
+		3 << 40:         "3.0 TiB",
+		-2048:           "-2.0 KiB",
+	}
+	for n, exp := range cases {
+		if res := FormatBytes(n); res != exp {
+			t.Errorf(T_STRING, n, exp, res)
+		}
+	}
+}
@@ -146,13 +146,31 @@ type JobRequest struct {
 	XMLName  xml.Name   `xml:"http://www.daisy.org/ns/pipeline/data jobRequest"`
 	Nicename string     `xml:"http://www.daisy.org/ns/pipeline/data nicename,omitempty"`
 	BatchId  string     `xml:"http://www.daisy.org/ns/pipeline/data batchId,omitempty"`
-	Priority string     `xml:"http://www.daisy.org/ns/pipeline/data priority,omitempty"`
+	Priority Priority   `xml:"http://www.daisy.org/ns/pipeline/data priority,omitempty"`
 	Script   Script     `xml:"http://www.daisy.org/ns/pipeline/data script"`
 	Inputs   []Input    `xml:"http://www.daisy.org/ns/pipeline/data input,omitempty"`
 	Options  []Option   `xml:"http://www.daisy.org/ns/pipeline/data option,omitempty"`
 	Callback []Callback `xml:"http://www.daisy.org/ns/pipeline/data callback,omitempty"`
 }
 
+//Job and client priorities
+type Priority string
+
+const (
+	PRIORITY_LOW    Priority = "low"
+	PRIORITY_MEDIUM Priority = "medium"
+	PRIORITY_HIGH   Priority = "high"
+)
+
+//Job statuses
+const (
+	JOB_IDLE    = "IDLE"
+	JOB_RUNNING = "RUNNING"
+	JOB_DONE    = "DONE"
+	JOB_ERROR   = "ERROR"
+	JOB_FAIL    = "FAIL"
+)
+
 type Job struct {
 	XMLName  xml.Name `xml:"http://www.daisy.org/ns/pipeline/data job"`
 	Nicename string   `xml:"http://www.daisy.org/ns/pipeline/data nicename"`
@@ -161,7 +179,7 @@ type Job struct {
 	Messages Messages `xml:"http://www.daisy.org/ns/pipeline/data messages"`
 	Log      Log      `xml:"http://www.daisy.org/ns/pipeline/data log"`
 	Results  Results  `xml:"http://www.daisy.org/ns/pipeline/data results"`
-	Priority string   `xml:"priority,attr"`
+	Priority Priority `xml:"priority,attr"`
 	Status   string   `xml:"status,attr"`
 	Href     string   `xml:"href,attr"`
 	Id       string   `xml:"id,attr"`
@@ -211,10 +229,10 @@ type Client struct {
 	XMLName  xml.Name `xml:"http://www.daisy.org/ns/pipeline/data client"`
 	Secret   string   `xml:"secret,attr"`
 	Href     string   `xml:"href,attr"`
-	Role     string   `xml:"role,attr"`
+	Role     Role     `xml:"role,attr"`
 	Id       string   `xml:"id,attr"`
 	Contact  string   `xml:"contact,attr"`
-	Priority string   `xml:"priority,attr"`
+	Priority Priority `xml:"priority,attr"`
 }
 type Property struct {
 	XMLName    xml.Name `xml:"http://www.daisy.org/ns/pipeline/data property"`
@@ -252,9 +270,9 @@ type QueueJob struct {
 	XMLName          xml.Name `xml:"http://www.daisy.org/ns/pipeline/data job"`
 	Moveup           string   `xml:"moveUp,attr"`
 	Id               string   `xml:"id,attr"`
-	ClientPriority   string   `xml:"clientPriority,attr"`
+	ClientPriority   Priority `xml:"clientPriority,attr"`
 	RelativeTime     float64  `xml:"relativeTime,attr"`
-	JobPriority      string   `xml:"jobPriority,attr"`
+	JobPriority      Priority `xml:"jobPriority,attr"`
 	Href             string   `xml:"href,attr"`
 	TimeStamp        int64    `xml:"timestamp,attr"`
 	MoveDown         string   `xml:"moveDown,attr"`
@@ -0,0 +1,142 @@
+package pipeline
+
+import (
+	"context"
+	"net/http"
+	"time"
+)
+
+//Span of an api call. It mirrors the part of the OpenTelemetry span used by
+//the instrumentation so adapting a trace.Span takes a few lines
+type Span interface {
+	SetAttribute(key string, value interface{})
+	RecordError(err error)
+	End()
+}
+
+//Starts spans, the returned context carries the new span
+type Tracer interface {
+	Start(ctx context.Context, name string) (context.Context, Span)
+}
+
+//Outcome of an api call
+type RequestMetrics struct {
+	Entry    string
+	Method   string
+	Status   int //0 if the server wasn't reached
+	Duration time.Duration
+	Sent     int64 //Bytes uploaded
+	Received int64 //Bytes downloaded
+	Err      error
+}
+
+//Duration of a job, from the moment it was first seen until it finished
+type JobMetrics struct {
+	JobId    string
+	ScriptId string
+	Status   string
+	Duration time.Duration
+}
+
+//Records the metrics, e.g. into OpenTelemetry histograms and counters
+type MetricsRecorder interface {
+	RecordRequest(ctx context.Context, m RequestMetrics)
+	RecordJob(ctx context.Context, m JobMetrics)
+}
+
+//Tracing and metrics of the api calls, nil fields are skipped
+type Instrumentation struct {
+	Tracer  Tracer
+	Metrics MetricsRecorder
+	//Writes the trace context into the outgoing headers, e.g. using
+	//propagation.HeaderCarrier with the OpenTelemetry propagator
+	Inject func(ctx context.Context, header http.Header)
+}
+
+//Instruments the pipeline, the instrumentation runs before the other
+//middlewares so it covers them
+func (p *Pipeline) Instrument(inst Instrumentation) {
+	p.middlewares = append([]Middleware{inst.Middleware()}, p.middlewares...)
+	p.metrics = inst.Metrics
+}
+
+//Returns a copy of the pipeline whose requests use the context, it's
+//cancelled with it and its spans are children of the one in ctx
+func (p Pipeline) WithContext(ctx context.Context) Pipeline {
+	p.ctx = ctx
+	return p
+}
+
+func (p Pipeline) context() context.Context {
+	if p.ctx == nil {
+		return context.Background()
+	}
+	return p.ctx
+}
+
+//Middleware creating a span per call named after the api entry and
+//recording the request metrics
+func (i Instrumentation) Middleware() Middleware {
+	return func(next Handler) Handler {
+		return func(req *Request) (status int, err error) {
+			ctx := req.Context
+			var span Span
+			if i.Tracer != nil {
+				ctx, span = i.Tracer.Start(ctx, req.Entry)
+				req.Context = ctx
+				span.SetAttribute("http.method", req.Method)
+				for name, value := range req.PathParams {
+					span.SetAttribute("pipeline."+name, value)
+				}
+				if jobReq, ok := req.Data.(*JobRequest); ok {
+					span.SetAttribute("pipeline.script", jobReq.Script.Id)
+				}
+			}
+			if i.Inject != nil {
+				if req.Header == nil {
+					req.Header = &http.Header{}
+				}
+				i.Inject(ctx, *req.Header)
+			}
+			start := time.Now()
+			status, err = next(req)
+			elapsed := time.Since(start)
+			if span != nil {
+				span.SetAttribute("http.status_code", status)
+				if job, ok := req.Result.(*Job); ok && job.Id != "" {
+					span.SetAttribute("pipeline.job", job.Id)
+				}
+				if err != nil {
+					span.RecordError(err)
+				}
+				span.End()
+			}
+			if i.Metrics != nil {
+				sent, received := transferred(req)
+				i.Metrics.RecordRequest(ctx, RequestMetrics{
+					Entry:    req.Entry,
+					Method:   req.Method,
+					Status:   status,
+					Duration: elapsed,
+					Sent:     sent,
+					Received: received,
+					Err:      err,
+				})
+			}
+			return
+		}
+	}
+}
+
+//Records the duration of a finished job
+func (p Pipeline) recordJob(job Job, elapsed time.Duration) {
+	if p.metrics == nil {
+		return
+	}
+	p.metrics.RecordJob(p.context(), JobMetrics{
+		JobId:    job.Id,
+		ScriptId: job.Script.Id,
+		Status:   job.Status,
+		Duration: elapsed,
+	})
+}
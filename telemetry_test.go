@@ -0,0 +1,157 @@
+package pipeline
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/capitancambio/restclient"
+)
+
+type ctxKey string
+
+type fakeSpan struct {
+	name   string
+	parent interface{}
+	attrs  map[string]interface{}
+	errs   []error
+	ended  bool
+}
+
+func (s *fakeSpan) SetAttribute(key string, value interface{}) { s.attrs[key] = value }
+func (s *fakeSpan) RecordError(err error)                      { s.errs = append(s.errs, err) }
+func (s *fakeSpan) End()                                       { s.ended = true }
+
+type fakeTracer struct {
+	spans []*fakeSpan
+}
+
+func (t *fakeTracer) Start(ctx context.Context, name string) (context.Context, Span) {
+	span := &fakeSpan{name: name, parent: ctx.Value(ctxKey("span")), attrs: make(map[string]interface{})}
+	t.spans = append(t.spans, span)
+	return context.WithValue(ctx, ctxKey("span"), name), span
+}
+
+type fakeMetrics struct {
+	mutex    sync.Mutex
+	requests []RequestMetrics
+	jobs     []JobMetrics
+}
+
+func (m *fakeMetrics) RecordRequest(ctx context.Context, r RequestMetrics) {
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+	m.requests = append(m.requests, r)
+}
+
+func (m *fakeMetrics) RecordJob(ctx context.Context, j JobMetrics) {
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+	m.jobs = append(m.jobs, j)
+}
+
+func TestInstrumentationSpans(t *testing.T) {
+	tracer := &fakeTracer{}
+	pipeline := createPipeline(xmlClientMock(jobStatus, 200))
+	pipeline.Instrument(Instrumentation{Tracer: tracer})
+	ctx := context.WithValue(context.Background(), ctxKey("span"), "parent")
+	if _, err := pipeline.WithContext(ctx).Job("job-id-01", 3); err != nil {
+		t.Fatalf("Unexpected error %v", err)
+	}
+	if len(tracer.spans) != 1 {
+		t.Fatalf(T_STRING, "spans", 1, len(tracer.spans))
+	}
+	span := tracer.spans[0]
+	if span.name != API_JOB || span.parent != "parent" || !span.ended {
+		t.Errorf("Wrong span %+v", span)
+	}
+	if span.attrs["pipeline.job"] != "job-id-01" || span.attrs["pipeline.msgSeq"] != "3" || span.attrs["http.status_code"] != 200 {
+		t.Errorf("Wrong attributes %v", span.attrs)
+	}
+}
+
+func TestInstrumentationErrors(t *testing.T) {
+	tracer := &fakeTracer{}
+	metrics := &fakeMetrics{}
+	pipeline := createPipeline(xmlClientMock(aliveXml, 503))
+	pipeline.Instrument(Instrumentation{Tracer: tracer, Metrics: metrics})
+	if _, err := pipeline.Alive(); err == nil {
+		t.Fatalf("Expected error")
+	}
+	if errs := tracer.spans[0].errs; len(errs) != 1 || errs[0] != restclient.UnexpectedStatus {
+		t.Errorf("Error not recorded %v", errs)
+	}
+	if len(metrics.requests) != 1 || metrics.requests[0].Status != 503 || metrics.requests[0].Entry != API_ALIVE {
+		t.Errorf("Wrong request metrics %+v", metrics.requests)
+	}
+}
+
+func TestInstrumentationInject(t *testing.T) {
+	cli := &MockClient{status: 204}
+	pipeline := createPipeline(func() doer { return cli })
+	pipeline.Instrument(Instrumentation{
+		Tracer: &fakeTracer{},
+		Inject: func(ctx context.Context, header http.Header) {
+			header.Set("traceparent", ctx.Value(ctxKey("span")).(string))
+		},
+	})
+	pipeline.DeleteJob("job")
+	if cli.request.Header == nil || cli.request.Header.Get("traceparent") != API_DEL_JOB {
+		t.Errorf("Trace context not propagated %v", cli.request.Header)
+	}
+}
+
+func TestInstrumentationIsOutermost(t *testing.T) {
+	metrics := &fakeMetrics{}
+	fault := errors.New("injected")
+	pipeline := createPipeline(xmlClientMock(aliveXml, 200))
+	pipeline.Use(func(next Handler) Handler {
+		return func(req *Request) (int, error) {
+			return 0, fault
+		}
+	})
+	pipeline.Instrument(Instrumentation{Metrics: metrics})
+	pipeline.Alive()
+	if len(metrics.requests) != 1 || metrics.requests[0].Err != fault {
+		t.Errorf("Middleware not covered %+v", metrics.requests)
+	}
+}
+
+func TestInstrumentationJobDuration(t *testing.T) {
+	running := strings.Replace(jobStatus, `status="DONE"`, `status="RUNNING"`, 1)
+	polls := 0
+	routes := map[string]mockHandler{
+		"GET jobs/job-id-01": func(*restclient.RequestResponse) (string, int) {
+			polls++
+			if polls < 3 {
+				return running, 200
+			}
+			return jobStatus, 200
+		},
+	}
+	metrics := &fakeMetrics{}
+	pipeline := createPipeline(routedClientMock(routes, &mockRecorder{}))
+	pipeline.Instrument(Instrumentation{Metrics: metrics})
+	watcher := NewJobWatcher(pipeline, nil)
+	watcher.Window = time.Millisecond
+	if _, err := watcher.Watch(context.Background(), "job-id-01", func(JobUpdate) {}); err != nil {
+		t.Fatalf("Unexpected error %v", err)
+	}
+	if len(metrics.jobs) != 1 || metrics.jobs[0].JobId != "job-id-01" || metrics.jobs[0].Status != JOB_DONE {
+		t.Errorf("Wrong job metrics %+v", metrics.jobs)
+	}
+	if len(metrics.requests) != polls {
+		t.Errorf(T_STRING, "requests", polls, len(metrics.requests))
+	}
+
+	//finished before watching
+	metrics.jobs = nil
+	watcher.Watch(context.Background(), "job-id-01", func(JobUpdate) {})
+	if len(metrics.jobs) != 0 {
+		t.Errorf("Job not observed running recorded %+v", metrics.jobs)
+	}
+}
@@ -0,0 +1,209 @@
+package pipeline
+
+import (
+	"fmt"
+	"net/url"
+	"regexp"
+	"strconv"
+	"strings"
+)
+
+//Named argument of an api entry url, built with the arg* functions so the
+//values have the right type
+type urlArg struct {
+	name  string
+	value string
+}
+
+func argJob(id string) urlArg      { return urlArg{"job", id} }
+func argMsgSeq(seq int) urlArg     { return urlArg{"msgSeq", strconv.Itoa(seq)} }
+func argScript(id string) urlArg   { return urlArg{"script", id} }
+func argDatatype(id string) urlArg { return urlArg{"datatype", id} }
+func argBatch(id string) urlArg    { return urlArg{"batch", id} }
+func argClient(id string) urlArg   { return urlArg{"client", id} }
+func argHaltKey(key string) urlArg { return urlArg{"key", key} }
+
+//Arguments left out of the request description as they are secret
+var secretArgs = map[string]bool{"key": true}
+
+var templateArgRegexp = regexp.MustCompile(`\{(\w+)\}`)
+
+//Names of the arguments of the url template, in order
+func templateArgs(template string) (names []string) {
+	for _, match := range templateArgRegexp.FindAllStringSubmatch(template, -1) {
+		names = append(names, match[1])
+	}
+	return
+}
+
+//Replaces the {name} placeholders of the template, e.g.
+//jobs/{job}?msgSeq={msgSeq}, with the escaped values of the arguments.
+//Values in the path are escaped as a path segment and values in the query
+//as a query value. Every placeholder needs an argument and every argument
+//has to be used
+func expandUrl(template string, args []urlArg) (string, error) {
+	values := make(map[string]string)
+	for _, arg := range args {
+		if _, ok := values[arg.name]; ok {
+			return "", fmt.Errorf("Argument %v given twice", arg.name)
+		}
+		values[arg.name] = arg.value
+	}
+	used := 0
+	var missing []string
+	expand := func(part string, escape func(string) string) string {
+		return templateArgRegexp.ReplaceAllStringFunc(part, func(placeholder string) string {
+			name := placeholder[1 : len(placeholder)-1]
+			value, ok := values[name]
+			if !ok {
+				missing = append(missing, name)
+				return placeholder
+			}
+			used++
+			return escape(value)
+		})
+	}
+	path, query := template, ""
+	if idx := strings.Index(template, "?"); idx >= 0 {
+		path, query = template[:idx], template[idx:]
+	}
+	res := expand(path, url.PathEscape) + expand(query, url.QueryEscape)
+	if len(missing) > 0 {
+		return "", fmt.Errorf("Missing arguments %v", strings.Join(missing, ", "))
+	}
+	if used != len(args) {
+		return "", fmt.Errorf("Unexpected arguments for %v", template)
+	}
+	return res, nil
+}
+
+//Replaces the placeholders of the secret arguments with REDACTED
+func redactTemplate(template string) string {
+	return templateArgRegexp.ReplaceAllStringFunc(template, func(placeholder string) string {
+		if secretArgs[placeholder[1:len(placeholder)-1]] {
+			return REDACTED
+		}
+		return placeholder
+	})
+}
+
+//Arguments that aren't secret
+func publicArgs(args []urlArg) (public []urlArg) {
+	for _, arg := range args {
+		if !secretArgs[arg.name] {
+			public = append(public, arg)
+		}
+	}
+	return
+}
+
+//Values of the non secret arguments
+func describeArgs(args []urlArg) map[string]string {
+	params := make(map[string]string)
+	for _, arg := range args {
+		if !secretArgs[arg.name] {
+			params[arg.name] = arg.value
+		}
+	}
+	return params
+}
+
+//Requests to the built-in api entries, one per entry so the arguments of
+//the url are checked by the compiler. newResquest only matches them by
+//name, it's left to Call and Probe
+
+func (p Pipeline) reqAlive(target interface{}) (*Request, error) {
+	return p.newResquest(API_ALIVE, target, nil)
+}
+
+func (p Pipeline) reqScripts(target interface{}) (*Request, error) {
+	return p.newResquest(API_SCRIPTS, target, nil)
+}
+
+func (p Pipeline) reqScript(target interface{}, id string) (*Request, error) {
+	return p.newResquest(API_SCRIPT, target, nil, argScript(id))
+}
+
+func (p Pipeline) reqDatatype(target interface{}, id string) (*Request, error) {
+	return p.newResquest(API_DATATYPE, target, nil, argDatatype(id))
+}
+
+func (p Pipeline) reqStylesheetParameters(target, data interface{}) (*Request, error) {
+	return p.newResquest(API_STYLESHEET_PARAMETERS, target, data)
+}
+
+func (p Pipeline) reqJobRequest(target, data interface{}) (*Request, error) {
+	return p.newResquest(API_JOBREQUEST, target, data)
+}
+
+func (p Pipeline) reqJob(target interface{}, id string, msgSeq int) (*Request, error) {
+	return p.newResquest(API_JOB, target, nil, argJob(id), argMsgSeq(msgSeq))
+}
+
+func (p Pipeline) reqDelJob(id string) (*Request, error) {
+	return p.newResquest(API_DEL_JOB, nil, nil, argJob(id))
+}
+
+func (p Pipeline) reqResult(target interface{}, id string) (*Request, error) {
+	return p.newResquest(API_RESULT, target, nil, argJob(id))
+}
+
+func (p Pipeline) reqJobs(target interface{}) (*Request, error) {
+	return p.newResquest(API_JOBS, target, nil)
+}
+
+func (p Pipeline) reqQueue(target interface{}) (*Request, error) {
+	return p.newResquest(API_QUEUE, target, nil)
+}
+
+func (p Pipeline) reqMoveUp(target interface{}, id string) (*Request, error) {
+	return p.newResquest(API_MOVE_UP, target, nil, argJob(id))
+}
+
+func (p Pipeline) reqMoveDown(target interface{}, id string) (*Request, error) {
+	return p.newResquest(API_MOVE_DOWN, target, nil, argJob(id))
+}
+
+func (p Pipeline) reqLog(target interface{}, id string) (*Request, error) {
+	return p.newResquest(API_LOG, target, nil, argJob(id))
+}
+
+func (p Pipeline) reqHalt(key string) (*Request, error) {
+	return p.newResquest(API_HALT, nil, nil, argHaltKey(key))
+}
+
+func (p Pipeline) reqClients(target interface{}) (*Request, error) {
+	return p.newResquest(API_CLIENTS, target, nil)
+}
+
+func (p Pipeline) reqNewClient(target, data interface{}) (*Request, error) {
+	return p.newResquest(API_NEWCLIENT, target, data)
+}
+
+func (p Pipeline) reqClient(target interface{}, id string) (*Request, error) {
+	return p.newResquest(API_CLIENT, target, nil, argClient(id))
+}
+
+func (p Pipeline) reqDeleteClient(id string) (*Request, error) {
+	return p.newResquest(API_DELETECLIENT, nil, nil, argClient(id))
+}
+
+func (p Pipeline) reqModifyClient(target, data interface{}, id string) (*Request, error) {
+	return p.newResquest(API_MODIFYCLIENT, target, data, argClient(id))
+}
+
+func (p Pipeline) reqProperties(target interface{}) (*Request, error) {
+	return p.newResquest(API_PROPERTIES, target, nil)
+}
+
+func (p Pipeline) reqSize(target interface{}) (*Request, error) {
+	return p.newResquest(API_SIZE, target, nil)
+}
+
+func (p Pipeline) reqBatch(target interface{}, id string) (*Request, error) {
+	return p.newResquest(API_BATCH, target, nil, argBatch(id))
+}
+
+func (p Pipeline) reqDelBatch(id string) (*Request, error) {
+	return p.newResquest(API_DEL_BATCH, nil, nil, argBatch(id))
+}
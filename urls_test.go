@@ -0,0 +1,127 @@
+package pipeline
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestExpandUrl(t *testing.T) {
+	tests := []struct {
+		template string
+		args     []urlArg
+		exp      string
+	}{
+		{"alive", nil, "alive"},
+		{"jobs/{job}?msgSeq={msgSeq}", []urlArg{argJob("job-1"), argMsgSeq(3)}, "jobs/job-1?msgSeq=3"},
+		{"jobs/{job}?msgSeq={msgSeq}", []urlArg{argMsgSeq(-1), argJob("a b/c?d")}, "jobs/a%20b%2Fc%3Fd?msgSeq=-1"},
+		{"admin/clients/{client}", []urlArg{argClient("me@example.org")}, "admin/clients/me@example.org"},
+		{"batch/{batch}", []urlArg{argBatch("../admin")}, "batch/..%2Fadmin"},
+		{"x?id={job}", []urlArg{argJob("a&b=c d")}, "x?id=a%26b%3Dc+d"},
+	}
+	for _, test := range tests {
+		res, err := expandUrl(test.template, test.args)
+		if err != nil {
+			t.Errorf("Unexpected error %v", err)
+		}
+		if res != test.exp {
+			t.Errorf(T_STRING, test.template, test.exp, res)
+		}
+	}
+}
+
+func TestExpandUrlErrors(t *testing.T) {
+	tests := map[string][]urlArg{
+		"missing":    {argJob("job")},
+		"unexpected": {argJob("job"), argMsgSeq(1), argScript("script")},
+		"twice":      {argJob("job"), argJob("other"), argMsgSeq(1)},
+	}
+	for name, args := range tests {
+		if res, err := expandUrl("jobs/{job}?msgSeq={msgSeq}", args); err == nil {
+			t.Errorf("%v arguments accepted: %v", name, res)
+		}
+	}
+	if _, err := expandUrl("jobs/{job}?msgSeq={msgSeq}", []urlArg{argJob("job")}); err == nil || !strings.Contains(err.Error(), "msgSeq") {
+		t.Errorf("Missing argument not reported %v", err)
+	}
+}
+
+func TestApiEntryTemplates(t *testing.T) {
+	known := map[string]bool{}
+	for _, arg := range []urlArg{argJob(""), argMsgSeq(0), argScript(""), argDatatype(""), argBatch(""), argClient(""), argHaltKey("")} {
+		known[arg.name] = true
+	}
+	for name, entry := range apiEntries {
+		if strings.Contains(entry.urlPath, "%") {
+			t.Errorf("%v still uses a format string %v", name, entry.urlPath)
+		}
+		for _, arg := range templateArgs(entry.urlPath) {
+			if !known[arg] {
+				t.Errorf("%v uses an argument without constructor: %v", name, arg)
+			}
+		}
+	}
+	if res := strings.Join(templateArgs(apiEntries[API_JOB].urlPath), ","); res != "job,msgSeq" {
+		t.Errorf(T_STRING, "args", "job,msgSeq", res)
+	}
+}
+
+func TestRequestEscapesIds(t *testing.T) {
+	cli := &MockClient{status: 204}
+	pipeline := createPipeline(func() doer { return cli })
+	pipeline.DeleteJob("a b/../c")
+	if exp := "base/jobs/a%20b%2F..%2Fc"; cli.request.Url != exp {
+		t.Errorf(T_STRING, "url", exp, cli.request.Url)
+	}
+	req, _ := pipeline.reqHalt("secret")
+	if len(req.PathParams) != 0 {
+		t.Errorf("Halt key described %v", req.PathParams)
+	}
+	req, _ = pipeline.reqJob(nil, "job", 2)
+	if req.PathParams["job"] != "job" || req.PathParams["msgSeq"] != "2" {
+		t.Errorf("Wrong params %v", req.PathParams)
+	}
+}
+
+func TestRequestConstructors(t *testing.T) {
+	p := createPipeline(emptyClientMock)
+	constructors := []func() (*Request, error){
+		func() (*Request, error) { return p.reqAlive(nil) },
+		func() (*Request, error) { return p.reqScripts(nil) },
+		func() (*Request, error) { return p.reqScript(nil, "s") },
+		func() (*Request, error) { return p.reqDatatype(nil, "d") },
+		func() (*Request, error) { return p.reqStylesheetParameters(nil, nil) },
+		func() (*Request, error) { return p.reqJobRequest(nil, nil) },
+		func() (*Request, error) { return p.reqJob(nil, "j", 0) },
+		func() (*Request, error) { return p.reqDelJob("j") },
+		func() (*Request, error) { return p.reqResult(nil, "j") },
+		func() (*Request, error) { return p.reqJobs(nil) },
+		func() (*Request, error) { return p.reqQueue(nil) },
+		func() (*Request, error) { return p.reqMoveUp(nil, "j") },
+		func() (*Request, error) { return p.reqMoveDown(nil, "j") },
+		func() (*Request, error) { return p.reqLog(nil, "j") },
+		func() (*Request, error) { return p.reqHalt("k") },
+		func() (*Request, error) { return p.reqClients(nil) },
+		func() (*Request, error) { return p.reqNewClient(nil, nil) },
+		func() (*Request, error) { return p.reqClient(nil, "c") },
+		func() (*Request, error) { return p.reqDeleteClient("c") },
+		func() (*Request, error) { return p.reqModifyClient(nil, nil, "c") },
+		func() (*Request, error) { return p.reqProperties(nil) },
+		func() (*Request, error) { return p.reqSize(nil) },
+		func() (*Request, error) { return p.reqBatch(nil, "b") },
+		func() (*Request, error) { return p.reqDelBatch("b") },
+	}
+	built := make(map[string]bool)
+	for _, constructor := range constructors {
+		req, err := constructor()
+		if err != nil {
+			t.Errorf("Unexpected error %v", err)
+			continue
+		}
+		built[req.Entry] = true
+	}
+	for name := range apiEntries {
+		if !customEntries[name] && !built[name] {
+			t.Errorf("No request constructor for %v", name)
+		}
+	}
+}
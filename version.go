@@ -0,0 +1,71 @@
+package pipeline
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+//Framework version as reported by Alive, e.g. 1.14.3-SNAPSHOT
+type Version struct {
+	Major, Minor, Patch int
+	Qualifier           string //Whatever follows the numeric part, e.g. SNAPSHOT
+}
+
+//Parses a version string, missing minor or patch numbers are taken as 0
+func ParseVersion(s string) (v Version, err error) {
+	s = strings.TrimSpace(s)
+	if s == "" {
+		return v, fmt.Errorf("Invalid version %q", s)
+	}
+	numeric := s
+	if idx := strings.IndexAny(s, "-+ "); idx >= 0 {
+		numeric, v.Qualifier = s[:idx], s[idx+1:]
+	}
+	parts := strings.Split(numeric, ".")
+	if len(parts) > 3 {
+		return Version{}, fmt.Errorf("Invalid version %q", s)
+	}
+	nums := []*int{&v.Major, &v.Minor, &v.Patch}
+	for i, part := range parts {
+		n, err := strconv.Atoi(part)
+		if err != nil || n < 0 {
+			return Version{}, fmt.Errorf("Invalid version %q", s)
+		}
+		*nums[i] = n
+	}
+	return v, nil
+}
+
+//Returns -1, 0 or 1 if v is lower, equal or greater than other. Qualifiers are ignored
+func (v Version) Compare(other Version) int {
+	a := []int{v.Major, v.Minor, v.Patch}
+	b := []int{other.Major, other.Minor, other.Patch}
+	for i := range a {
+		if a[i] < b[i] {
+			return -1
+		}
+		if a[i] > b[i] {
+			return 1
+		}
+	}
+	return 0
+}
+
+//Tells whether v is equal or greater than other
+func (v Version) AtLeast(other Version) bool {
+	return v.Compare(other) >= 0
+}
+
+//Tells whether the version is the zero value
+func (v Version) IsZero() bool {
+	return v == Version{}
+}
+
+func (v Version) String() string {
+	s := fmt.Sprintf("%d.%d.%d", v.Major, v.Minor, v.Patch)
+	if v.Qualifier != "" {
+		s += "-" + v.Qualifier
+	}
+	return s
+}
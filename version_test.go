@@ -0,0 +1,39 @@
+package pipeline
+
+import "testing"
+
+func TestParseVersion(t *testing.T) {
+	cases := map[string]Version{
+		"1.6":             {Major: 1, Minor: 6},
+		"1.14.3-SNAPSHOT": {Major: 1, Minor: 14, Patch: 3, Qualifier: "SNAPSHOT"},
+		"2":               {Major: 2},
+	}
+	for s, exp := range cases {
+		v, err := ParseVersion(s)
+		if err != nil {
+			t.Errorf("Unexpected error %v", err)
+		}
+		if v != exp {
+			t.Errorf(T_STRING, s, exp, v)
+		}
+	}
+	for _, s := range []string{"", "a.b", "1.2.3.4", "1.-1"} {
+		if _, err := ParseVersion(s); err == nil {
+			t.Errorf("Expected error for %q", s)
+		}
+	}
+}
+
+func TestVersionCompare(t *testing.T) {
+	v1, _ := ParseVersion("1.10.0")
+	v2, _ := ParseVersion("1.9.5")
+	if !v1.AtLeast(v2) || v2.AtLeast(v1) {
+		t.Errorf("Wrong comparison %v %v", v1, v2)
+	}
+	if v1.Compare(Version{Major: 1, Minor: 10, Qualifier: "SNAPSHOT"}) != 0 {
+		t.Errorf("Qualifiers should be ignored")
+	}
+	if v1.String() != "1.10.0" {
+		t.Errorf(T_STRING, "string", "1.10.0", v1.String())
+	}
+}
@@ -0,0 +1,164 @@
+package pipeline
+
+import (
+	"context"
+	"sync"
+	"time"
+)
+
+//Default time without news from the framework before asking for the job status
+const DEFAULT_WATCH_WINDOW = 5 * time.Second
+
+//Tells whether the job status is final
+func Finished(status string) bool {
+	return status == JOB_DONE || status == JOB_ERROR || status == JOB_FAIL
+}
+
+//Update of a job being watched
+type JobUpdate struct {
+	Job      Job       //Last known state of the job
+	Messages []Message //Messages not delivered in previous updates
+	Polled   bool      //The update comes from polling instead of a callback
+}
+
+//Tracks jobs through the callbacks posted to a CallbackReceiver, polling the
+//framework when no callback arrives within Window so missed callbacks don't
+//leave the job hanging. Without receiver it just polls every Window.
+type JobWatcher struct {
+	Window   time.Duration //Time without callbacks before polling
+	pipeline Pipeline
+	receiver *CallbackReceiver
+}
+
+//Creates a watcher, receiver may be nil
+func NewJobWatcher(p Pipeline, receiver *CallbackReceiver) *JobWatcher {
+	return &JobWatcher{
+		Window:   DEFAULT_WATCH_WINDOW,
+		pipeline: p,
+		receiver: receiver,
+	}
+}
+
+//Callbacks waiting to be processed by Watch
+type callbackQueue struct {
+	mutex  sync.Mutex
+	jobs   []Job
+	notify chan struct{}
+}
+
+func (q *callbackQueue) push(_ CallbackType, job Job) {
+	q.mutex.Lock()
+	q.jobs = append(q.jobs, job)
+	q.mutex.Unlock()
+	select {
+	case q.notify <- struct{}{}:
+	default:
+	}
+}
+
+func (q *callbackQueue) pop() (jobs []Job) {
+	q.mutex.Lock()
+	defer q.mutex.Unlock()
+	jobs, q.jobs = q.jobs, nil
+	return
+}
+
+//Keeps the state of a single watch
+type watchState struct {
+	job     Job
+	lastSeq int
+	seen    map[int]bool
+	fn      func(JobUpdate)
+}
+
+//Merges the job into the state and notifies the new messages if any
+func (s *watchState) update(job Job, polled bool) {
+	var fresh []Message
+	for _, msg := range job.Messages.Message {
+		if s.seen[msg.Sequence] {
+			continue
+		}
+		s.seen[msg.Sequence] = true
+		if msg.Sequence > s.lastSeq {
+			s.lastSeq = msg.Sequence
+		}
+		fresh = append(fresh, msg)
+	}
+	if Finished(s.job.Status) {
+		//late updates can't bring a finished job back
+		job.Status = s.job.Status
+	}
+	changed := job.Status != s.job.Status
+	if job.Id == "" {
+		job.Id = s.job.Id
+	}
+	s.job = job
+	if changed || len(fresh) > 0 {
+		s.fn(JobUpdate{Job: job, Messages: fresh, Polled: polled})
+	}
+}
+
+//Watches the job until it finishes or the context is done, fn is called every
+//time the status changes or new messages arrive. Messages are delivered once,
+//no matter if they come from a callback or from polling
+func (w *JobWatcher) Watch(ctx context.Context, id string, fn func(JobUpdate)) (job Job, err error) {
+	pipeline := w.pipeline.WithContext(ctx)
+	observer := func(u JobUpdate) {
+		pipeline.observeJob(u.Job)
+		fn(u)
+	}
+	state := &watchState{job: Job{Id: id}, lastSeq: -1, seen: make(map[int]bool), fn: observer}
+	start := time.Now()
+	queue := &callbackQueue{notify: make(chan struct{}, 1)}
+	if w.receiver != nil {
+		//other handlers of the job, e.g. another watcher, are kept
+		remove := w.receiver.Handle(id, queue.push)
+		defer remove()
+	}
+	poll := func() error {
+		job, err := pipeline.Job(id, state.lastSeq)
+		if err != nil {
+			return err
+		}
+		state.update(job, true)
+		return nil
+	}
+	if err = poll(); err != nil {
+		return state.job, err
+	}
+	//jobs already finished weren't observed running
+	observed := !Finished(state.job.Status)
+	timer := time.NewTimer(w.Window)
+	defer timer.Stop()
+	for !Finished(state.job.Status) {
+		select {
+		case <-ctx.Done():
+			return state.job, ctx.Err()
+		case <-queue.notify:
+			for _, job := range queue.pop() {
+				state.update(job, false)
+			}
+			if Finished(state.job.Status) && w.receiver != nil {
+				//catch up with the messages possibly missed
+				if err = poll(); err != nil {
+					return state.job, err
+				}
+			}
+		case <-timer.C:
+			if err = poll(); err != nil {
+				return state.job, err
+			}
+		}
+		if !timer.Stop() {
+			select {
+			case <-timer.C:
+			default:
+			}
+		}
+		timer.Reset(w.Window)
+	}
+	if observed {
+		pipeline.recordJob(state.job, time.Since(start))
+	}
+	return state.job, nil
+}
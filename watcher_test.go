@@ -0,0 +1,131 @@
+package pipeline
+
+import (
+	"context"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/capitancambio/restclient"
+)
+
+func TestWatchPolling(t *testing.T) {
+	pipeline := createPipeline(xmlClientMock(jobStatus, 200))
+	watcher := NewJobWatcher(pipeline, nil)
+	var updates []JobUpdate
+	job, err := watcher.Watch(context.Background(), "job-id-01", func(u JobUpdate) {
+		updates = append(updates, u)
+	})
+	if err != nil {
+		t.Errorf("Unexpected error %v", err)
+	}
+	if job.Status != JOB_DONE {
+		t.Errorf(T_STRING, "status", JOB_DONE, job.Status)
+	}
+	if len(updates) != 1 || !updates[0].Polled || len(updates[0].Messages) != 1 {
+		t.Errorf("Wrong updates %+v", updates)
+	}
+}
+
+func TestWatchCallbacks(t *testing.T) {
+	running := strings.Replace(jobStatus, `status="DONE"`, `status="RUNNING"`, 1)
+	pipeline := createPipeline(xmlClientMock(running, 200))
+	receiver := NewCallbackReceiver("http://localhost:9999/callbacks/")
+	watcher := NewJobWatcher(pipeline, receiver)
+	watcher.Window = time.Minute
+	var updates []JobUpdate
+	go func() {
+		//wait for the watcher to register
+		for {
+			receiver.mutex.RLock()
+			_, ok := receiver.handlers["job-id-01"]
+			receiver.mutex.RUnlock()
+			if ok {
+				break
+			}
+			time.Sleep(time.Millisecond)
+		}
+		msgs := strings.Replace(running, `sequence="22"`, `sequence="23"`, 1)
+		postCallback(receiver, "http://localhost:9999/callbacks/messages", msgs)
+		//already seen
+		postCallback(receiver, "http://localhost:9999/callbacks/messages", running)
+		postCallback(receiver, "http://localhost:9999/callbacks/status", jobCreationOk)
+	}()
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	job, err := watcher.Watch(ctx, "job-id-01", func(u JobUpdate) {
+		updates = append(updates, u)
+	})
+	if err != nil {
+		t.Fatalf("Unexpected error %v", err)
+	}
+	if job.Status != JOB_DONE {
+		t.Errorf(T_STRING, "status", JOB_DONE, job.Status)
+	}
+	msgs := 0
+	for _, u := range updates {
+		msgs += len(u.Messages)
+	}
+	if msgs != 2 {
+		t.Errorf(T_STRING, "messages", 2, msgs)
+	}
+	if updates[len(updates)-1].Polled || updates[len(updates)-1].Job.Status != JOB_DONE {
+		t.Errorf("Last update should be the status callback %+v", updates[len(updates)-1])
+	}
+}
+
+func TestWatchKeepsOtherHandlers(t *testing.T) {
+	pipeline := createPipeline(xmlClientMock(jobStatus, 200))
+	receiver := NewCallbackReceiver("http://localhost:9999/callbacks/")
+	mine := 0
+	receiver.Handle("job-id-01", func(CallbackType, Job) { mine++ })
+	if _, err := NewJobWatcher(pipeline, receiver).Watch(context.Background(), "job-id-01", func(JobUpdate) {}); err != nil {
+		t.Fatalf("Unexpected error %v", err)
+	}
+	postCallback(receiver, "http://localhost:9999/callbacks/status", jobStatus)
+	if mine != 1 || len(receiver.handlers["job-id-01"]) != 1 {
+		t.Errorf("Handler registered by the caller removed")
+	}
+}
+
+func TestWatchFillsHistory(t *testing.T) {
+	running := strings.Replace(jobStatus, `status="DONE"`, `status="RUNNING"`, 1)
+	polls := 0
+	pipeline := createPipeline(routedClientMock(map[string]mockHandler{
+		"GET jobs/job-id-01": func(*restclient.RequestResponse) (string, int) {
+			if polls++; polls == 1 {
+				return running, 200
+			}
+			return jobStatus, 200
+		},
+	}, &mockRecorder{}))
+	history := NewDurationHistory(10)
+	pipeline.SetDurationHistory(history)
+	watcher := NewJobWatcher(pipeline, nil)
+	watcher.Window = time.Millisecond
+	if _, err := watcher.Watch(context.Background(), "job-id-01", func(JobUpdate) {}); err != nil {
+		t.Fatalf("Unexpected error %v", err)
+	}
+	if _, ok := history.Mean(); !ok {
+		t.Errorf("Duration of the job not recorded")
+	}
+	if estimator := NewQueueEstimator(pipeline, nil); estimator.History != history {
+		t.Errorf("Estimator doesn't use the history of the pipeline")
+	}
+}
+
+func TestWatchTimeout(t *testing.T) {
+	running := strings.Replace(jobStatus, `status="DONE"`, `status="RUNNING"`, 1)
+	pipeline := createPipeline(xmlClientMock(running, 200))
+	watcher := NewJobWatcher(pipeline, nil)
+	watcher.Window = time.Millisecond
+	ctx, cancel := context.WithTimeout(context.Background(), 20*time.Millisecond)
+	defer cancel()
+	job, err := watcher.Watch(ctx, "job-id-01", func(JobUpdate) {})
+	if err != context.DeadlineExceeded {
+		t.Errorf(T_STRING, "error", context.DeadlineExceeded, err)
+	}
+	if job.Status != JOB_RUNNING {
+		t.Errorf(T_STRING, "status", JOB_RUNNING, job.Status)
+	}
+}